@@ -2,43 +2,191 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/mojochao/devbox/internal/credentials"
 	"github.com/mojochao/devbox/internal/devbox"
 )
 
 // addCmd represents the add command
 var addCmd = &cobra.Command{
-	Use:   "add ID IMAGE [flags]",
+	Use:   "add ID [IMAGE] [flags]",
 	Short: "Add devbox with ID using IMAGE to state",
 	Long: `Devboxes must be added before they can be started and used.
 
-Devboxes are identified a unique ID, but many devboxes can use the same image.`,
+Devboxes are identified a unique ID, but many devboxes can use the same image.
+IMAGE is optional for devboxes added from a devcontainer.json file, and names
+the image built for them if provided.
+
+Forwarded agents and credentials are relayed by shell sessions, which requires
+socat to be installed in the devbox image.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Ensure correct usage.
 		if len(args) < 1 {
 			exit(1, "missing ID argument")
 		}
-		if len(args) < 2 {
+		fromDevContainer, _ := cmd.Flags().GetString("from-devcontainer")
+		if len(args) < 2 && fromDevContainer == "" {
 			exit(1, "missing IMAGE argument")
 		}
 		if len(args) > 2 {
 			exit(1, "extra arguments found")
 		}
 		id := args[0]
-		image := args[1]
+		image := ""
+		if len(args) > 1 {
+			image = args[1]
+		}
 		user, _ := cmd.Flags().GetString("user")
 		shell, _ := cmd.Flags().GetString("shell")
 		name, _ := cmd.Flags().GetString("name")
 		namespace, _ := cmd.Flags().GetString("namespace")
 		kubeconfig, _ := cmd.Flags().GetString("kubeconfig")
 		description, _ := cmd.Flags().GetString("description")
+		cpuRequest, _ := cmd.Flags().GetString("cpu-request")
+		cpuLimit, _ := cmd.Flags().GetString("cpu-limit")
+		memoryRequest, _ := cmd.Flags().GetString("memory-request")
+		memoryLimit, _ := cmd.Flags().GetString("memory-limit")
+		storageRequest, _ := cmd.Flags().GetString("ephemeral-storage-request")
+		storageLimit, _ := cmd.Flags().GetString("ephemeral-storage-limit")
+		ulimits, _ := cmd.Flags().GetStringSlice("ulimit")
+		nodeSelector, _ := cmd.Flags().GetStringToString("node-selector")
+		serviceAccount, _ := cmd.Flags().GetString("service-account")
+
+		// Parse scheduling controls.
+		tolerationSpecs, _ := cmd.Flags().GetStringSlice("toleration")
+		var tolerations []devbox.Toleration
+		for _, spec := range tolerationSpecs {
+			toleration, err := devbox.ParseToleration(spec)
+			exitOnError(err, 1, "invalid --toleration flag")
+			tolerations = append(tolerations, toleration)
+		}
+		secretMounts := parseObjectMounts(cmd, "secret-mount")
+		configMapMounts := parseObjectMounts(cmd, "configmap-mount")
+		imagePullSecrets, _ := cmd.Flags().GetStringSlice("image-pull-secret")
+		var affinity map[string]interface{}
+		affinityFile, _ := cmd.Flags().GetString("affinity-file")
+		if affinityFile != "" {
+			var err error
+			affinity, err = devbox.LoadAffinity(affinityFile)
+			exitOnError(err, 1, fmt.Sprintf("cannot load affinity from %s", affinityFile))
+		}
 
 		// Load state.
 		state, err := devbox.LoadState(stateFile)
 		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
 
+		// Load pod patch.
+		var podPatch *devbox.PodPatch
+		podPatchFile, _ := cmd.Flags().GetString("pod-patch")
+		podPatchType, _ := cmd.Flags().GetString("pod-patch-type")
+		if podPatchFile != "" {
+			podPatch, err = devbox.NewPodPatch(podPatchFile, podPatchType)
+			exitOnError(err, 1, fmt.Sprintf("invalid pod patch %s", podPatchFile))
+		}
+
+		readinessCommand, _ := cmd.Flags().GetString("readiness-command")
+		startTimeout, _ := cmd.Flags().GetString("start-timeout")
+
+		var keepAlive *devbox.KeepAlive
+		keepAliveEnabled, _ := cmd.Flags().GetBool("keep-alive")
+		keepAliveCommand, _ := cmd.Flags().GetStringArray("keep-alive-command")
+		keepAliveArgs, _ := cmd.Flags().GetStringArray("keep-alive-arg")
+		if keepAliveEnabled || len(keepAliveCommand) > 0 || len(keepAliveArgs) > 0 {
+			keepAlive, err = devbox.NewKeepAlive(keepAliveCommand, keepAliveArgs)
+			exitOnError(err, 1, "invalid keep-alive")
+		}
+
+		var build *devbox.Build
+		buildContext, _ := cmd.Flags().GetString("build-context")
+		dockerfile, _ := cmd.Flags().GetString("dockerfile")
+		buildArgs, _ := cmd.Flags().GetStringToString("build-arg")
+		if buildContext != "" {
+			build, err = devbox.NewBuild(buildContext, dockerfile, buildArgs)
+			exitOnError(err, 1, fmt.Sprintf("invalid build context %s", buildContext))
+		} else if dockerfile != "" || len(buildArgs) > 0 {
+			exit(1, "--dockerfile and --build-arg flags require the --build-context flag")
+		}
+
+		var verification *devbox.Verification
+		verifyKey, _ := cmd.Flags().GetString("verify-key")
+		requireVerified, _ := cmd.Flags().GetBool("require-verified")
+		if verifyKey != "" {
+			verification, err = devbox.NewVerification(verifyKey, requireVerified)
+			exitOnError(err, 1, fmt.Sprintf("invalid verification key %s", verifyKey))
+		} else if requireVerified {
+			exit(1, "--require-verified flag requires the --verify-key flag")
+		}
+
+		forwardSSHAgent, _ := cmd.Flags().GetBool("forward-ssh-agent")
+		forwardGPGAgent, _ := cmd.Flags().GetBool("forward-gpg-agent")
+		forwardGitCredentials, _ := cmd.Flags().GetBool("forward-git-credentials")
+		secretPolicy, _ := cmd.Flags().GetString("secret-policy")
+		var creds []credentials.Source
+		credentialsFlags, _ := cmd.Flags().GetStringSlice("credentials")
+		for _, flag := range credentialsFlags {
+			source, err := credentials.ParseSource(flag)
+			exitOnError(err, 1, fmt.Sprintf("invalid credentials %s", flag))
+			creds = append(creds, source)
+		}
+
+		// Resolve docker network and compose project.
+		network, _ := cmd.Flags().GetString("network")
+		composeProject, _ := cmd.Flags().GetString("compose-project")
+		compose, _ := cmd.Flags().GetBool("compose")
+		if compose && composeProject == "" {
+			composeProject, err = devbox.DiscoverComposeProject(".")
+			exitOnError(err, 1, "cannot discover compose project")
+			fmt.Printf("discovered compose project %s\n", composeProject)
+		}
+
+		// Parse security profile and run as user.
+		securityProfile, _ := cmd.Flags().GetString("security-profile")
+		var runAs *devbox.RunAs
+		runAsUser, _ := cmd.Flags().GetString("run-as-user")
+		if runAsUser != "" {
+			runAs, err = devbox.ParseRunAs(runAsUser)
+			exitOnError(err, 1, "invalid --run-as-user flag")
+		}
+
+		// Translate devcontainer.json configuration, unless overridden by flags.
+		var env map[string]string
+		var mounts []devbox.Mount
+		var ports []int
+		var setupCommands [][]string
+		if fromDevContainer != "" {
+			dc, err := devbox.LoadDevContainer(fromDevContainer)
+			exitOnError(err, 1, fmt.Sprintf("cannot load devcontainer from %s", fromDevContainer))
+			if len(dc.Unsupported) > 0 {
+				fmt.Printf("warning: ignoring unsupported keys in %s: %s\n", dc.Path, strings.Join(dc.Unsupported, ", "))
+			}
+			if image == "" {
+				image = dc.Image
+				if image == "" {
+					image = fmt.Sprintf("devbox-%s:latest", id)
+				}
+			}
+			if !cmd.Flags().Changed("user") && dc.User != "" {
+				user = dc.User
+			}
+			if description == "" {
+				description = dc.Name
+			}
+			if build == nil {
+				build = dc.Build
+			}
+			if namespace != "" && len(dc.Mounts) > 0 {
+				fmt.Printf("warning: ignoring mounts in %s, only supported for Docker devboxes\n", dc.Path)
+			} else {
+				mounts = dc.Mounts
+			}
+			env = dc.Env
+			ports = dc.Ports
+			setupCommands = dc.SetupCommands
+		}
+
 		// AddDevbox devbox to state.
 		box := devbox.New(&devbox.Config{
 			Image:       image,
@@ -48,7 +196,81 @@ Devboxes are identified a unique ID, but many devboxes can use the same image.`,
 			Namespace:   namespace,
 			Kubeconfig:  kubeconfig,
 			Description: description,
+			Resources: devbox.Resources{
+				Requests: devbox.ResourceList{
+					CPU:              cpuRequest,
+					Memory:           memoryRequest,
+					EphemeralStorage: storageRequest,
+				},
+				Limits: devbox.ResourceList{
+					CPU:              cpuLimit,
+					Memory:           memoryLimit,
+					EphemeralStorage: storageLimit,
+				},
+				Ulimits: ulimits,
+			},
+			NodeSelector:     nodeSelector,
+			Tolerations:      tolerations,
+			SecretMounts:     secretMounts,
+			ConfigMapMounts:  configMapMounts,
+			ImagePullSecrets: imagePullSecrets,
+			Affinity:         affinity,
+			ServiceAccount:   serviceAccount,
+			PodPatch:         podPatch,
+			Readiness: devbox.Readiness{
+				Command: readinessCommand,
+				Timeout: startTimeout,
+			},
+			KeepAlive:             keepAlive,
+			Build:                 build,
+			Env:                   env,
+			Mounts:                mounts,
+			Ports:                 ports,
+			SetupCommands:         setupCommands,
+			Network:               network,
+			ComposeProject:        composeProject,
+			ForwardSSHAgent:       forwardSSHAgent,
+			ForwardGPGAgent:       forwardGPGAgent,
+			ForwardGitCredentials: forwardGitCredentials,
+			SecretPolicy:          secretPolicy,
+			Credentials:           creds,
+			Verification:          verification,
+			SecurityProfile:       securityProfile,
+			RunAs:                 runAs,
 		})
+
+		// Pin image to its current digest.
+		pinDigest, _ := cmd.Flags().GetBool("pin-digest")
+		if pinDigest {
+			if box.Build != nil {
+				exit(1, "--pin-digest flag cannot be used with images built by devbox")
+			}
+			box.Digest, err = devbox.ResolveDigest(box.Image)
+			exitOnError(err, 1, fmt.Sprintf("cannot resolve digest of image %s", box.Image))
+			fmt.Printf("pinned image %s to digest %s\n", box.Image, box.Digest)
+		}
+
+		// Resolve the IDs of the devbox user in its pinned and verified image,
+		// which the restricted security profile requires to run as non-root.
+		if box.SecurityProfile == devbox.SecurityRestricted && box.RunAs == nil && box.Namespace != "" {
+			if box.Build != nil {
+				exit(1, "--security-profile restricted flag requires the --run-as-user flag for images built by devbox")
+			}
+			box, err = box.ResolveRunAs()
+			exitOnError(err, 1, "cannot resolve run as user, provide the --run-as-user flag")
+			fmt.Printf("running as user %d and group %d\n", box.RunAs.User, box.RunAs.Group)
+		}
+
+		err = box.Validate()
+		exitOnError(err, 1, fmt.Sprintf("invalid devbox %s", id))
+
+		// Ensure any pod patch applies to the pod manifest, rather than
+		// failing when the devbox is first started.
+		if box.PodPatch != nil {
+			_, err = box.PodManifest()
+			exitOnError(err, 1, fmt.Sprintf("invalid pod patch %s", box.PodPatch.Path))
+		}
+
 		err = state.AddDevbox(id, box)
 		exitOnError(err, 1, fmt.Sprintf("cannot add devbox %s", id))
 
@@ -67,4 +289,42 @@ func init() {
 	addCmd.Flags().StringP("namespace", "n", "", "Devbox pod namespace (Kubernetes devboxes only)")
 	addCmd.Flags().StringP("kubeconfig", "k", "", "Devbox cluster kubeconfig (Kubernetes devboxes only)")
 	addCmd.Flags().StringP("description", "d", "", "Devbox description")
+	addCmd.Flags().String("cpu-request", "", "Devbox CPU request, e.g. 500m")
+	addCmd.Flags().String("cpu-limit", "", "Devbox CPU limit, e.g. 2")
+	addCmd.Flags().String("memory-request", "", "Devbox memory request, e.g. 512Mi")
+	addCmd.Flags().String("memory-limit", "", "Devbox memory limit, e.g. 4Gi")
+	addCmd.Flags().String("ephemeral-storage-request", "", "Devbox ephemeral storage request, e.g. 1Gi")
+	addCmd.Flags().String("ephemeral-storage-limit", "", "Devbox ephemeral storage limit, e.g. 10Gi (requires overlay2 on xfs with pquota for Docker devboxes)")
+	addCmd.Flags().StringSlice("ulimit", []string{}, "Devbox ulimits, e.g. nofile=90000:90000, or none to keep the docker daemon ulimits (Docker devboxes only)")
+	addCmd.Flags().StringToString("node-selector", map[string]string{}, "Devbox pod node selector labels (Kubernetes devboxes only)")
+	addCmd.Flags().StringSlice("toleration", []string{}, "Devbox pod toleration in key[=value][:effect] format (Kubernetes devboxes only)")
+	addCmd.Flags().String("affinity-file", "", "Devbox pod affinity YAML file (Kubernetes devboxes only)")
+	addCmd.Flags().String("service-account", "", "Devbox pod service account (Kubernetes devboxes only)")
+	addCmd.Flags().StringSlice("secret-mount", []string{}, "Devbox secret mounted in NAME[/KEY]:PATH format (Kubernetes devboxes only)")
+	addCmd.Flags().StringSlice("configmap-mount", []string{}, "Devbox config map mounted in NAME[/KEY]:PATH format (Kubernetes devboxes only)")
+	addCmd.Flags().StringSlice("image-pull-secret", []string{}, "Devbox pod image pull secret (Kubernetes devboxes only)")
+	addCmd.Flags().Bool("keep-alive", false, "Devbox overrides the image entrypoint to sleep forever")
+	addCmd.Flags().StringArray("keep-alive-command", []string{}, "Devbox keep-alive entrypoint command, repeated for each word (implies --keep-alive)")
+	addCmd.Flags().StringArray("keep-alive-arg", []string{}, "Devbox keep-alive entrypoint argument, repeated for each argument (requires --keep-alive-command)")
+	addCmd.Flags().String("pod-patch", "", "Devbox pod manifest patch file (Kubernetes devboxes only)")
+	addCmd.Flags().String("pod-patch-type", "strategic", "Devbox pod manifest patch type, strategic, merge or json (Kubernetes devboxes only)")
+	addCmd.Flags().String("readiness-command", "", "Devbox shell command that must succeed for a started devbox to be ready")
+	addCmd.Flags().String("start-timeout", "", "Devbox start readiness timeout, e.g. 10m (default 5m)")
+	addCmd.Flags().String("build-context", "", "Devbox image build context directory, building IMAGE when started and pushing it for Kubernetes devboxes")
+	addCmd.Flags().String("dockerfile", "", "Devbox image Dockerfile, relative to the build context (default Dockerfile)")
+	addCmd.Flags().StringToString("build-arg", map[string]string{}, "Devbox image build args")
+	addCmd.Flags().String("from-devcontainer", "", "Devcontainer.json file, or directory containing it, to translate to the devbox")
+	addCmd.Flags().String("network", "", "Devbox docker network (Docker devboxes only)")
+	addCmd.Flags().String("compose-project", "", "Devbox docker compose project whose networks are joined (Docker devboxes only)")
+	addCmd.Flags().Bool("compose", false, "Devbox joins the networks of the docker compose project of the current directory (Docker devboxes only)")
+	addCmd.Flags().Bool("forward-ssh-agent", false, "Devbox forwards the local SSH agent")
+	addCmd.Flags().Bool("forward-gpg-agent", false, "Devbox forwards the local GPG agent")
+	addCmd.Flags().Bool("forward-git-credentials", false, "Devbox forwards git credential requests to the local git credential helpers")
+	addCmd.Flags().Bool("pin-digest", false, "Devbox image pinned to its current digest")
+	addCmd.Flags().String("verify-key", "", "Devbox image signature cosign public key, verifying the image before start or attach")
+	addCmd.Flags().Bool("require-verified", false, "Devbox refuses to start if its image signature cannot be verified")
+	addCmd.Flags().StringSlice("credentials", []string{}, "Devbox short-lived cloud credentials injected from local CLIs, in PROVIDER[:PROFILE] format where PROVIDER is aws, gcp or azure")
+	addCmd.Flags().String("secret-policy", "", "Devbox setup secret policy, block, warn or allow, see setup help (default block for Kubernetes, warn for Docker)")
+	addCmd.Flags().String("security-profile", "", "Devbox pod security profile, restricted, baseline or privileged (Kubernetes devboxes only)")
+	addCmd.Flags().String("run-as-user", "", "Devbox pod user and group IDs in UID[:GID] format, resolved from the image for the restricted profile if not provided (Kubernetes devboxes only)")
 }
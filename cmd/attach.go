@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mojochao/devbox/internal/devbox"
+)
+
+// attachCmd represents the attach command
+var attachCmd = &cobra.Command{
+	Use:   "attach [ID]",
+	Short: "Attach devbox to an existing workload",
+	Long: `Debugging inside running containers is often impossible, as their images
+lack the tools needed, or have no shell at all. This command attaches a devbox
+to an existing workload, sets it up and opens a shell in it, so that it can be
+debugged with the tools of the devbox image.
+
+Kubernetes devboxes are attached to the pod provided with the --target-pod
+flag as an ephemeral container sharing the process namespace of the container
+provided with the --target-container flag, or the first container in the pod
+if not provided. The processes of the target container are visible from the
+devbox, and its filesystem is available at /proc/PID/root.
+
+Kubernetes devboxes are attached to the deployment or statefulset provided in
+kind/name format with the --target-workload flag by patching them into its pod
+template as a sidecar container sharing the volume mounts of the container
+provided with the --target-container flag, or the first container if not
+provided. Secrets and ConfigMaps mounted in the devbox are added to the pod
+template as volumes too. The original pod template is recorded in state and
+restored when the devbox is stopped. Workloads in protected namespaces, which
+are kube-system, kube-public and kube-node-lease and any others added to the
+protectedNamespaces list of the state file, are never patched.
+
+Docker devboxes are attached to the container provided with the
+--target-container flag by running them in its network and process namespaces.
+Services listening in the target container are reachable on localhost, and if
+the --link-root flag is provided, its filesystem is linked at /target in the
+devbox. Attached Docker devboxes are stopped and removed when the shell exits,
+unless the --cleanup=false flag is provided.
+
+If no ID argument is provided, any set in the active devbox context will be
+used.
+
+Setup of the attached devbox is selected with the --include and --exclude
+flags as with the setup command, and can be skipped with the --setup=false
+flag.
+
+Ephemeral containers cannot be removed from a pod once added. Stopping an
+attached devbox stops its container, which remains listed in the pod status
+until the pod is deleted, and a devbox cannot be attached twice to the same
+pod with the same container name.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Ensure correct usage.
+		if len(args) > 1 {
+			exit(1, "only one ID argument allowed")
+		}
+		targetPod, _ := cmd.Flags().GetString("target-pod")
+		targetContainer, _ := cmd.Flags().GetString("target-container")
+		targetWorkload, _ := cmd.Flags().GetString("target-workload")
+		if targetPod == "" && targetWorkload == "" && targetContainer == "" {
+			exit(1, "missing --target-pod, --target-workload or --target-container flag")
+		}
+		if targetPod != "" && targetWorkload != "" {
+			exit(1, "only one of --target-pod and --target-workload flags allowed")
+		}
+		linkRoot, _ := cmd.Flags().GetBool("link-root")
+		cleanup, _ := cmd.Flags().GetBool("cleanup")
+		setup, _ := cmd.Flags().GetBool("setup")
+		manifestTypes := getManifestTypes(cmd)
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		shell, _ := cmd.Flags().GetString("shell")
+
+		// Load state.
+		state, err := devbox.LoadState(stateFile)
+		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
+
+		// Ensure we have a devbox id.
+		id := state.Active
+		if len(args) == 1 {
+			id = args[0]
+		}
+		id = ensureDevboxID(state, id)
+
+		// Load devbox by id.
+		box, err := state.GetDevbox(id)
+		exitOnError(err, 1, fmt.Sprintf("devbox %s not found", id))
+		if timeout == 0 {
+			timeout = box.StartTimeout()
+		}
+
+		// Attach devbox and save its attachment in state so that it can be
+		// used by other commands and stopped.
+		switch {
+		case box.Namespace == "":
+			if targetPod != "" || targetWorkload != "" {
+				exit(1, fmt.Sprintf("devbox %s is a Docker devbox and can only be attached to containers", id))
+			}
+			box, err = box.AttachContainer(targetContainer, linkRoot, timeout)
+		case targetWorkload != "":
+			kind, name, parseErr := devbox.ParseWorkload(targetWorkload)
+			exitOnError(parseErr, 1, "invalid --target-workload flag")
+			box, err = box.AttachWorkload(kind, name, targetContainer, state.ProtectedNamespaces, timeout)
+		case targetPod != "":
+			box, err = box.AttachPod(targetPod, targetContainer, timeout)
+		default:
+			exit(1, fmt.Sprintf("devbox %s is a Kubernetes devbox and requires a --target-pod or --target-workload flag", id))
+		}
+
+		// The attachment is saved even if attaching failed after it was made,
+		// so that it can be undone with the stop command.
+		if box.Attachment != nil && !dryRun {
+			saveErr := state.UpdateDevbox(id, box)
+			exitOnError(saveErr, 1, fmt.Sprintf("cannot save state to %s", stateFile))
+		}
+
+		// Stop attached Docker devbox once its shell exits, or on any exit
+		// before then, even if attaching, setup or the shell failed, or the
+		// session was hung up, terminated or interrupted.
+		shellOpen := exitOnSignal()
+		if cleanup && box.Namespace == "" && box.Attachment != nil {
+			onExit(func() {
+				if stopErr := box.Stop(); stopErr != nil {
+					fmt.Printf("warning: cannot stop devbox %s: %v\n", id, stopErr)
+					return
+				}
+				recordStopped(state, id, box)
+				fmt.Printf("devbox %s stopped\n", id)
+			})
+		}
+		exitOnError(err, 1, fmt.Sprintf("cannot attach devbox %s", id))
+		box = recordStarted(state, id, box)
+		fmt.Printf("devbox %s attached to %s\n", id, box.Attachment)
+
+		// Setup devbox and open shell on it.
+		if setup {
+			box = setupDevbox(state, id, box, manifestTypes, devbox.SetupOptions{})
+		}
+		atomic.StoreInt32(shellOpen, 1)
+		err = box.OpenShell(shell)
+		atomic.StoreInt32(shellOpen, 0)
+
+		runExitHooks()
+		exitOnError(err, 1, "cannot open shell")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(attachCmd)
+	attachCmd.Flags().String("target-pod", "", "Pod to attach devbox to (Kubernetes devboxes only)")
+	attachCmd.Flags().String("target-workload", "", "Deployment or statefulset in kind/name format to patch devbox into (Kubernetes devboxes only)")
+	attachCmd.Flags().String("target-container", "", "Container whose namespaces or volumes are shared with devbox")
+	attachCmd.Flags().Bool("link-root", false, "Link target container filesystem at /target in devbox (Docker devboxes only)")
+	attachCmd.Flags().Bool("cleanup", true, "Stop devbox when shell exits (Docker devboxes only)")
+	attachCmd.Flags().Bool("setup", true, "Setup devbox once attached")
+	attachCmd.Flags().StringSliceP("include", "i", devbox.ManifestTypes, "Manifest types to include in setup")
+	attachCmd.Flags().StringSliceP("exclude", "e", []string{}, "Manifest types to exclude in setup")
+	attachCmd.Flags().Duration("timeout", 0, "Maximum time to wait for devbox to run (default is devbox start timeout)")
+	attachCmd.Flags().StringP("shell", "s", "", "shell name or path")
+}
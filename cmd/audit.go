@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mojochao/devbox/internal/audit"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the audit log of devbox commands",
+	Long: `Every devbox command is recorded in an audit log file, with when it was run,
+the local user running it, its arguments, the devboxes it operated on and the
+Docker container or Kubernetes pod they run in, the local files it copied to
+them, and its exit status. Flags are not recorded, as their values may be
+secrets. The audit log file is provided with the --audit-log flag, and is
+disabled if empty.
+
+This command displays the entries of the audit log selected by its flags. The
+--box flag selects entries of commands operating on a devbox, the --user flag
+of commands run by a user, the --command flag of commands run with a
+subcommand such as start, and the --failed flag of commands that failed. The
+--since flag selects entries newer than a relative duration like 24h or an
+RFC3339 timestamp.
+
+If the --output=json flag is provided, the selected entries are displayed in
+JSON lines format, including the files copied, rather than as a table.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Ensure correct usage.
+		if len(args) > 0 {
+			exit(1, "no arguments allowed")
+		}
+		output, _ := cmd.Flags().GetString("output")
+		if output != "" && output != "json" {
+			exit(1, fmt.Sprintf("invalid output format %s, want json", output))
+		}
+		box, _ := cmd.Flags().GetString("box")
+		user, _ := cmd.Flags().GetString("user")
+		command, _ := cmd.Flags().GetString("command")
+		failed, _ := cmd.Flags().GetBool("failed")
+		since, _ := cmd.Flags().GetString("since")
+		filter := audit.Filter{Box: box, User: user, Command: command, Failed: failed}
+		if since != "" {
+			var err error
+			filter.Since, err = parseSince(since)
+			exitOnError(err, 1, "invalid --since flag")
+		}
+		if auditLog == "" {
+			exit(1, "audit log is disabled")
+		}
+
+		// Query audit log.
+		entries, err := audit.Query(auditLog, filter)
+		exitOnError(err, 1, fmt.Sprintf("cannot read audit log %s", auditLog))
+
+		// Display audit log entries.
+		if output == "json" {
+			for _, entry := range entries {
+				buf, err := json.Marshal(entry)
+				exitOnError(err, 1, "cannot marshal audit log entry")
+				fmt.Println(string(buf))
+			}
+			return
+		}
+		printAuditTable(entries)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.Flags().String("box", "", "Select commands operating on devbox with ID")
+	auditCmd.Flags().String("user", "", "Select commands run by local user")
+	auditCmd.Flags().String("command", "", "Select commands run with subcommand, e.g. start")
+	auditCmd.Flags().String("since", "", "Select commands newer than a relative duration like 24h or an RFC3339 timestamp")
+	auditCmd.Flags().Bool("failed", false, "Select commands that failed")
+	auditCmd.Flags().StringP("output", "o", "", "Output format, json for JSON lines")
+}
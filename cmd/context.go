@@ -23,7 +23,9 @@ If an ID argument is not provided the current active devbox ID context will be
 displayed.
 
 If the global --verbose flag is provided, full details on the active devbox ID
-will be displayed.
+will be displayed, including when it was last started and stopped, the
+manifest types it was last setup with, and the image digest and container or
+pod ID it was last started with.
 
 If the local --reset flag is provided, the current active devbox context will
 be reset.`,
@@ -59,7 +61,7 @@ be reset.`,
 			box, err := state.GetDevbox(state.Active)
 			exitOnError(err, 1, fmt.Sprintf("devbox %s not found", state.Active))
 			boxes := devbox.Boxes{state.Active: box}
-			printBoxesTable(boxes)
+			printBoxesTable(boxes, true)
 			return
 		}
 
@@ -15,7 +15,10 @@ var editCmd = &cobra.Command{
 	Short: "Edit the state file",
 	Long: `The devbox application stores its state in a state file. This command opens the
 state file in the editor configured in the EDITOR environment variable, or the
-value of the --editor or -e flags if that environment variable is not set.`,
+value of the --editor or -e flags if that environment variable is not set.
+
+Fields encrypted by the encrypt command are shown encrypted, and can be
+replaced with plain values, which are encrypted when state is next saved.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		editor, ok := os.LookupEnv("EDITOR")
 		if !ok {
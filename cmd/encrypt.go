@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mojochao/devbox/internal/devbox"
+)
+
+// encryptCmd represents the encrypt command
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt sensitive devbox fields in the state file",
+	Long: `The state file stores kubeconfig paths, descriptions and environment
+variables of devboxes, which may reveal infrastructure or contain tokens. This
+command encrypts these fields of all devboxes in the state file with AES-GCM,
+and they stay encrypted whenever state is saved. Kubeconfig paths are then
+also omitted from the audit log. The state file itself is always only readable
+by its owner.
+
+The --key flag selects where the encryption key is kept. With the keyring key,
+the default, a random key is created in the OS keyring, with secret-tool on
+Linux and security on macOS. With the age key, the key is derived from the
+secret key of the age identity file provided with the --age-identity flag,
+which must then be kept, as the state cannot be decrypted without it.
+
+If the --disable flag is provided, the fields are decrypted and stored in
+plain text again.
+
+Encrypted fields are shown encrypted by the edit command, and can be replaced
+with plain values, which are encrypted when state is next saved.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Ensure correct usage.
+		if len(args) > 0 {
+			exit(1, "no arguments allowed")
+		}
+		key, _ := cmd.Flags().GetString("key")
+		identity, _ := cmd.Flags().GetString("age-identity")
+		disable, _ := cmd.Flags().GetBool("disable")
+
+		// Load state.
+		state, err := devbox.LoadState(stateFile)
+		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
+
+		// Configure encryption.
+		msg := fmt.Sprintf("decrypted state in %s", stateFile)
+		state.Encryption = nil
+		if !disable {
+			state.Encryption, err = devbox.NewEncryption(key, identity)
+			exitOnError(err, 1, "invalid encryption")
+			if !dryRun {
+				err = state.Encryption.EnsureKey()
+				exitOnError(err, 1, fmt.Sprintf("cannot get %s encryption key", key))
+			}
+			msg = fmt.Sprintf("encrypted state in %s with %s key", stateFile, key)
+		}
+
+		// Save state.
+		if !dryRun {
+			err = state.Save()
+			exitOnError(err, 1, fmt.Sprintf("cannot save state to %s", stateFile))
+		}
+		fmt.Println(msg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+	encryptCmd.Flags().String("key", devbox.KeySourceKeyring, "Encryption key source, keyring or age")
+	encryptCmd.Flags().String("age-identity", "", "Age identity file the encryption key is derived from")
+	encryptCmd.Flags().Bool("disable", false, "Decrypt state")
+}
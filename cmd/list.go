@@ -15,22 +15,31 @@ var listCmd = &cobra.Command{
 	Short:   "List devboxes in state",
 	Long: `Multiple devboxes may be managed in application state.
 
-This command displays their ids and devbox data.`,
+This command displays their ids and devbox data.
+
+If the --output=wide flag is provided, when devboxes were last started and
+stopped, the manifest types they were last setup with, and the image digest
+and container or pod ID they were last started with are also displayed.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Ensure correct usage.
 		if len(args) > 0 {
 			exit(1, "no arguments allowed")
 		}
+		output, _ := cmd.Flags().GetString("output")
+		if output != "" && output != "wide" {
+			exit(1, fmt.Sprintf("invalid output format %s, want wide", output))
+		}
 
 		// Load state.
 		state, err := devbox.LoadState(stateFile)
 		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
 
 		// Display devboxes table.
-		printBoxesTable(state.Boxes)
+		printBoxesTable(state.Boxes, output == "wide")
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringP("output", "o", "", "Output format, wide to include runtime details")
 }
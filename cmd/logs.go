@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mojochao/devbox/internal/devbox"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs [ID]",
+	Short: "Show devbox logs",
+	Long: `When a devbox exits or restarts unexpectedly, its logs often show why. This
+command shows the logs of the devbox Docker container or Kubernetes pod.
+
+If no ID argument is provided, any set in the active devbox context will be
+used.
+
+If the --follow flag is provided, new logs are streamed until interrupted.
+
+The --since flag limits logs to those newer than a relative duration like 10m
+or an RFC3339 timestamp, and the --tail flag to a number of recent lines.
+
+Docker devbox containers are kept when they exit, until the devbox is stopped
+or started again, so their logs show why they exited. Kubernetes devbox pods
+restart their container when it exits. The logs of the previous container,
+which usually show why it exited, are shown if the --previous flag is
+provided.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Ensure correct usage.
+		if len(args) > 1 {
+			exit(1, "only one ID argument allowed")
+		}
+		follow, _ := cmd.Flags().GetBool("follow")
+		since, _ := cmd.Flags().GetString("since")
+		tail, _ := cmd.Flags().GetInt("tail")
+		previous, _ := cmd.Flags().GetBool("previous")
+
+		// Load state.
+		state, err := devbox.LoadState(stateFile)
+		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
+
+		// Ensure we have a devbox id.
+		id := state.Active
+		if len(args) == 1 {
+			id = args[0]
+		}
+		id = ensureDevboxID(state, id)
+
+		// Load devbox by id.
+		box, err := state.GetDevbox(id)
+		exitOnError(err, 1, fmt.Sprintf("devbox %s not found", id))
+
+		// Show devbox logs.
+		err = box.Logs(devbox.LogOptions{
+			Follow:   follow,
+			Since:    since,
+			Tail:     tail,
+			Previous: previous,
+		})
+		exitOnError(err, 1, fmt.Sprintf("cannot show logs of devbox %s", id))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().BoolP("follow", "f", false, "Stream new logs")
+	logsCmd.Flags().String("since", "", "Show logs newer than a duration like 10m or an RFC3339 timestamp")
+	logsCmd.Flags().Int("tail", -1, "Number of recent lines to show, or all if negative")
+	logsCmd.Flags().BoolP("previous", "p", false, "Show logs of previous container of restarted pod (Kubernetes devboxes only)")
+}
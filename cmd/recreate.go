@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mojochao/devbox/internal/devbox"
+)
+
+// recreateCmd represents the recreate command
+var recreateCmd = &cobra.Command{
+	Use:   "recreate [ID...]",
+	Short: "Recreate devboxes",
+	Long: `Replaces started devboxes with new ones running the latest version of their
+image. The image of Docker devboxes is pulled before their container is
+removed and run again, while Kubernetes devbox pods are deleted and created
+again with an Always image pull policy. Images of devboxes with a build
+configuration are built instead of pulled, when their inputs have changed or
+if the --rebuild flag is provided.
+
+Unlike the restart command, changes made to the filesystem of recreated
+devboxes are lost.
+
+If no ID arguments are provided, any set in the active devbox context will be used.
+
+This command waits until recreated devboxes are ready as with the start command.
+
+If the --setup flag is provided, recreated devboxes are setup again with the
+manifest types of their last setup recorded in state.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		setup, _ := cmd.Flags().GetBool("setup")
+		rebuild, _ := cmd.Flags().GetBool("rebuild")
+
+		// Load state.
+		state, err := devbox.LoadState(stateFile)
+		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
+
+		// Set ids of devboxes to recreate.
+		if len(args) == 0 && state.Active != "" {
+			args = []string{state.Active}
+		}
+		for _, id := range args {
+			id = ensureDevboxID(state, id)
+		}
+
+		// Recreate devboxes.
+		for _, id := range args {
+			box, err := state.GetDevbox(id)
+			exitOnError(err, 1, fmt.Sprintf("devbox %s not found", id))
+
+			box = buildDevbox(state, id, box, rebuild)
+			err = box.Recreate()
+			exitOnError(err, 1, fmt.Sprintf("cannot recreate devbox %s", id))
+			waitDevbox(id, box, timeout)
+			box = recordStarted(state, id, box)
+			fmt.Printf("devbox %s recreated\n", id)
+
+			if setup && len(box.Runtime.SetupTypes) > 0 {
+				setupDevbox(state, id, box, box.Runtime.SetupTypes, devbox.SetupOptions{})
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(recreateCmd)
+	recreateCmd.Flags().Duration("timeout", 0, "Maximum time to wait for devboxes to be ready (default is devbox start timeout)")
+	recreateCmd.Flags().Bool("rebuild", false, "Rebuild images of devboxes with a build configuration even if up to date")
+	recreateCmd.Flags().Bool("setup", false, "Setup devboxes again with the manifest types of their last setup")
+}
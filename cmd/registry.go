@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mojochao/devbox/internal/devbox"
+)
+
+// registryCmd represents the registry command
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Manage devbox image registry access",
+	Long: `Kubernetes devboxes whose images are in private registries require image
+pull secrets to be started. The subcommands of this command manage them.`,
+}
+
+// registryLoginCmd represents the registry login command
+var registryLoginCmd = &cobra.Command{
+	Use:   "login [ID]",
+	Short: "Create devbox image pull secret from local docker credentials",
+	Long: `Creates or updates a docker registry secret in the namespace of a Kubernetes
+devbox with the credentials of its image registry from the local docker config
+file, and adds it to the image pull secrets of the devbox in state. Credentials
+stored by docker credential helpers are supported.
+
+If no ID argument is provided, any set in the active devbox context will be
+used.
+
+The registry is derived from the devbox image, unless provided with the
+--registry flag. The secret is named devbox-registry-REGISTRY, unless named
+with the --secret flag. The docker config file used is provided with the
+--docker-config flag.
+
+Log in to the registry with docker login first. Registries whose credentials
+are identity tokens, which only docker can use, are not supported. Pull secrets
+that already exist can be used by devboxes with the --image-pull-secret flag of
+the add command instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Ensure correct usage.
+		if len(args) > 1 {
+			exit(1, "only one ID argument allowed")
+		}
+		registry, _ := cmd.Flags().GetString("registry")
+		secret, _ := cmd.Flags().GetString("secret")
+		dockerConfig, _ := cmd.Flags().GetString("docker-config")
+
+		// Load state.
+		state, err := devbox.LoadState(stateFile)
+		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
+
+		// Ensure we have a devbox id.
+		id := state.Active
+		if len(args) == 1 {
+			id = args[0]
+		}
+		id = ensureDevboxID(state, id)
+
+		// Load devbox by id.
+		box, err := state.GetDevbox(id)
+		exitOnError(err, 1, fmt.Sprintf("devbox %s not found", id))
+
+		// Create image pull secret.
+		if registry == "" {
+			registry = devbox.RegistryHost(box.Image)
+		}
+		if secret == "" {
+			secret = devbox.RegistrySecretName(registry)
+		}
+		box, err = box.RegistryLogin(registry, secret, dockerConfig)
+		exitOnError(err, 1, fmt.Sprintf("cannot log devbox %s in to registry %s", id, registry))
+		if !dryRun {
+			err = state.UpdateDevbox(id, box)
+			exitOnError(err, 1, fmt.Sprintf("cannot save state to %s", stateFile))
+		}
+		fmt.Printf("devbox %s pulls images from registry %s with secret %s\n", id, registry, secret)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(registryCmd)
+	registryCmd.AddCommand(registryLoginCmd)
+	registryLoginCmd.Flags().String("registry", "", "Registry host (default registry of devbox image)")
+	registryLoginCmd.Flags().String("secret", "", "Image pull secret name (default devbox-registry-REGISTRY)")
+	registryLoginCmd.Flags().String("docker-config", devbox.DefaultDockerConfig(), "Docker config file")
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mojochao/devbox/internal/devbox"
+)
+
+// restartCmd represents the restart command
+var restartCmd = &cobra.Command{
+	Use:   "restart [ID...]",
+	Short: "Restart devboxes",
+	Long: `Restarts started devboxes. Docker devbox containers are restarted in place,
+preserving their filesystem and volumes. Kubernetes devbox pods cannot be
+restarted, so they are deleted and created again.
+
+If no ID arguments are provided, any set in the active devbox context will be used.
+
+This command waits until restarted devboxes are ready as with the start command.
+
+If the --setup flag is provided, restarted devboxes are setup again with the
+manifest types of their last setup recorded in state.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		setup, _ := cmd.Flags().GetBool("setup")
+
+		// Load state.
+		state, err := devbox.LoadState(stateFile)
+		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
+
+		// Set ids of devboxes to restart.
+		if len(args) == 0 && state.Active != "" {
+			args = []string{state.Active}
+		}
+		for _, id := range args {
+			id = ensureDevboxID(state, id)
+		}
+
+		// Restart devboxes.
+		for _, id := range args {
+			box, err := state.GetDevbox(id)
+			exitOnError(err, 1, fmt.Sprintf("devbox %s not found", id))
+
+			err = box.Restart()
+			exitOnError(err, 1, fmt.Sprintf("cannot restart devbox %s", id))
+			waitDevbox(id, box, timeout)
+			box = recordStarted(state, id, box)
+			fmt.Printf("devbox %s restarted\n", id)
+
+			if setup && len(box.Runtime.SetupTypes) > 0 {
+				setupDevbox(state, id, box, box.Runtime.SetupTypes, devbox.SetupOptions{})
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restartCmd)
+	restartCmd.Flags().Duration("timeout", 0, "Maximum time to wait for devboxes to be ready (default is devbox start timeout)")
+	restartCmd.Flags().Bool("setup", false, "Setup devboxes again with the manifest types of their last setup")
+}
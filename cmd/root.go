@@ -7,12 +7,14 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/mojochao/devbox/internal/audit"
 	"github.com/mojochao/devbox/internal/config"
 	"github.com/mojochao/devbox/internal/devbox"
 )
 
 // These variables are set by persistent flags on the rootCmd.
 var (
+	auditLog  string
 	dryRun    bool
 	stateFile string
 	verbose   bool
@@ -37,12 +39,22 @@ A devbox is defined in terms of:
 
 Note that a devbox is intended to be more "pet" than "cattle", more persistent
 than ephemeral.  Any files copied to the devbox will be lost once stopped.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Hidden commands, such as shell completion requests, are not audited.
+		if !cmd.Hidden {
+			audit.Begin(cmd.CommandPath(), args, dryRun)
+		}
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		endAudit(0, "")
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		endAudit(1, err.Error())
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -53,10 +65,12 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&stateFile, "state", devbox.DefaultStateFile, "state file")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "preview commands")
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "show verbose output")
+	rootCmd.PersistentFlags().StringVar(&auditLog, "audit-log", audit.DefaultLogFile, "audit log file, disabled if empty")
 }
 
 // initConfig sets the global config.
 func initConfig() {
+	config.AuditLog = auditLog
 	config.StateFile = stateFile
 	config.DryRun = dryRun
 	config.Verbose = verbose
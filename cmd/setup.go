@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -19,7 +20,24 @@ the local user's home directory by a manifest type.
 If no --include flags are provided, all manifest types are included by default.
 If any --include flags are provided, only those manifest types will be setup.
 
-If any --exclude flags are provided, those manifest types will not be setup.`,
+If any --exclude flags are provided, those manifest types will not be setup.
+
+Files copied are checked for private keys, cloud credentials and tokens, which
+are handled according to the secret policy of the devbox, or the one provided
+with the --secret-policy flag:
+
+  block  skips files containing secrets, the default for Kubernetes devboxes
+  warn   copies files containing credentials and tokens with a warning, but
+         skips private keys, the default for Docker devboxes
+  allow  copies all files without checking them
+
+Skipped files are logged. Use SSH agent forwarding, enabled with the
+--forward-ssh-agent flag of the add command, to use private keys in devboxes
+instead of copying them.
+
+The manifest types setup are recorded in state, and can be setup again when
+the devbox is restarted or recreated with the --setup flag of the restart and
+recreate commands.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Load state.
 		state, err := devbox.LoadState(stateFile)
@@ -35,36 +53,18 @@ If any --exclude flags are provided, those manifest types will not be setup.`,
 		}
 
 		// Ensure valid includes and excludes.
-		manifestTypes := devbox.ManifestTypes
-		includes, _ := cmd.Flags().GetStringSlice("include")
-		if len(includes) > 0 {
-			for _, include := range includes {
-				if !util.ContainsString(manifestTypes, include) {
-					exit(1, fmt.Sprintf("invalid manifest type %s in --include flag", include))
-				}
-			}
-		} else {
-			includes = devbox.ManifestTypes
+		manifestTypes := getManifestTypes(cmd)
+		secretPolicy, _ := cmd.Flags().GetString("secret-policy")
+		if secretPolicy != "" && !util.ContainsString(devbox.SecretPolicies, secretPolicy) {
+			exit(1, fmt.Sprintf("invalid secret policy %s, want one of %s", secretPolicy, strings.Join(devbox.SecretPolicies, ", ")))
 		}
-		excludes, _ := cmd.Flags().GetStringSlice("exclude")
 
 		// Setup devboxes.
 		for _, id := range ids {
 			box, err := state.GetDevbox(id)
 			exitOnError(err, 1, fmt.Sprintf("devbox %s not found", id))
 
-			for _, manifestType := range manifestTypes {
-				if len(includes) > 0 && !util.ContainsString(includes, manifestType) {
-					continue
-				}
-				if len(excludes) > 0 && util.ContainsString(excludes, manifestType) {
-					continue
-				}
-
-				fmt.Printf("setting up devbox %s with %s config\n", id, manifestType)
-				err = box.Setup(manifestType)
-				exitOnError(err, 1, fmt.Sprintf("cannot setup devbox %s with %s config", id, manifestType))
-			}
+			setupDevbox(state, id, box, manifestTypes, devbox.SetupOptions{SecretPolicy: secretPolicy})
 		}
 	},
 }
@@ -73,4 +73,5 @@ func init() {
 	rootCmd.AddCommand(setupCmd)
 	setupCmd.Flags().StringSliceP("include", "i", devbox.ManifestTypes, "Manifest types to include in setup")
 	setupCmd.Flags().StringSliceP("exclude", "e", []string{}, "Manifest types to exclude in setup")
+	setupCmd.Flags().String("secret-policy", "", "Secret policy overriding that of the devbox, block, warn or allow")
 }
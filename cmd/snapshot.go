@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mojochao/devbox/internal/devbox"
+)
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot [ID]",
+	Short: "Snapshot devbox to an image",
+	Long: `Setting up a devbox and installing tools in it can take a long time to
+reproduce. This command commits the container of a started Docker devbox to an
+image tagged with the required --tag flag, capturing all changes made to it.
+
+If no ID argument is provided, any set in the active devbox context will be
+used.
+
+If the --push flag is provided, the snapshot image is pushed to its registry.
+Setup may have copied files containing secrets to the devbox, such as cloud
+credentials and tokens warned of by the default secret policy of Docker
+devboxes, which would be pushed with the snapshot. The snapshot is not pushed
+if any such files are found in the local files of its last setup, unless the
+--allow-secrets flag is provided. Files copied otherwise, such as by setup
+commands, cannot be detected.
+
+If the --update flag is provided, the devbox image is updated to the snapshot
+image in state, so that it is used the next time the devbox is started. Any
+build configuration of the devbox, which would replace the snapshot, and any
+pinned digest and signature verification of its previous image are removed.
+
+Kubernetes devboxes cannot be snapshotted, as Kubernetes container runtimes
+cannot commit containers to images. Instead, either:
+
+- snapshot a Docker devbox of the same image set up the same way, push the
+  snapshot image with the --push flag, and update the image of the Kubernetes
+  devbox to it by editing state with the edit command
+- capture the setup in a Dockerfile based on the devbox image, and add the
+  devbox with the --build-context flag of the add command, so that its image
+  is built and pushed when started, or built by a CI job
+- on clusters whose nodes run Docker, commit the devbox container on its node
+  with docker commit, which requires access to the node`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Ensure correct usage.
+		if len(args) > 1 {
+			exit(1, "only one ID argument allowed")
+		}
+		tag, _ := cmd.Flags().GetString("tag")
+		if tag == "" {
+			exit(1, "missing --tag flag")
+		}
+		push, _ := cmd.Flags().GetBool("push")
+		allowSecrets, _ := cmd.Flags().GetBool("allow-secrets")
+		update, _ := cmd.Flags().GetBool("update")
+
+		// Load state.
+		state, err := devbox.LoadState(stateFile)
+		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
+
+		// Ensure we have a devbox id.
+		id := state.Active
+		if len(args) == 1 {
+			id = args[0]
+		}
+		id = ensureDevboxID(state, id)
+
+		// Load devbox by id.
+		box, err := state.GetDevbox(id)
+		exitOnError(err, 1, fmt.Sprintf("devbox %s not found", id))
+
+		// Ensure no secrets copied by setup are pushed.
+		if push {
+			secrets, err := box.SetupSecrets()
+			exitOnError(err, 1, fmt.Sprintf("cannot detect secrets copied to devbox %s", id))
+			var paths []string
+			for path := range secrets {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				fmt.Printf("warning: setup may have copied %s to devbox %s, detected %s\n", path, id, secrets[path])
+			}
+			if len(secrets) > 0 && !allowSecrets {
+				exit(1, fmt.Sprintf("devbox %s may contain secrets, not pushing its snapshot without the --allow-secrets flag", id))
+			}
+		}
+
+		// Snapshot devbox.
+		err = box.Snapshot(tag, push)
+		exitOnError(err, 1, fmt.Sprintf("cannot snapshot devbox %s", id))
+		fmt.Printf("devbox %s snapshotted to image %s\n", id, tag)
+
+		// Update devbox image to snapshot.
+		if update {
+			box.Image = tag
+			box.Digest = ""
+			box.Verification = nil
+			box.Build = nil
+			box.Runtime.BuildHash = ""
+			if !dryRun {
+				err = state.UpdateDevbox(id, box)
+				exitOnError(err, 1, fmt.Sprintf("cannot save state to %s", stateFile))
+			}
+			fmt.Printf("devbox %s image updated to %s\n", id, tag)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.Flags().StringP("tag", "t", "", "Snapshot image tag")
+	snapshotCmd.Flags().Bool("push", false, "Push snapshot image")
+	snapshotCmd.Flags().Bool("allow-secrets", false, "Push snapshot image even if setup may have copied secrets to the devbox")
+	snapshotCmd.Flags().Bool("update", false, "Update devbox image to snapshot image")
+}
@@ -17,8 +17,36 @@ in Docker containers or Kubernetes pods.
 
 If no ID arguments are provided, any set in the active devbox context will be used.
 
+Kubernetes devboxes are started by creating a pod from a generated manifest.
+If the global --dry-run flag and the local --output flag are provided, that
+manifest will be displayed in the requested yaml or json format instead of the
+command creating it.
+
+Devboxes with a build configuration have their image built from a Dockerfile
+and tagged before they are started, and pushed for Kubernetes devboxes. Images
+are only rebuilt when the Dockerfile, build args or build context files have
+changed since they were last built, or if the --rebuild flag is provided.
+
+Unless the --wait=false flag is provided, this command waits until started
+devboxes are running and any readiness command configured for them succeeds.
+Waiting fails if a devbox container exits, its image cannot be pulled, or the
+--timeout flag or configured start timeout elapses.
+
 Once started, devboxes can be used by opening a shell session with the shell command.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		// Ensure correct usage.
+		output, _ := cmd.Flags().GetString("output")
+		if output != "" && output != "yaml" && output != "json" {
+			exit(1, fmt.Sprintf("invalid output format %s, want yaml or json", output))
+		}
+		if output != "" && !dryRun {
+			exit(1, "--output flag requires the --dry-run flag")
+		}
+
+		wait, _ := cmd.Flags().GetBool("wait")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		rebuild, _ := cmd.Flags().GetBool("rebuild")
+
 		// Load state.
 		state, err := devbox.LoadState(stateFile)
 		exitOnError(err, 1, fmt.Sprintf("cannot load state from %s", stateFile))
@@ -36,9 +64,31 @@ Once started, devboxes can be used by opening a shell session with the shell com
 			box, err := state.GetDevbox(id)
 			exitOnError(err, 1, fmt.Sprintf("devbox %s not found", id))
 
+			if output != "" {
+				if box.Namespace == "" {
+					exit(1, fmt.Sprintf("devbox %s is not a Kubernetes devbox and has no manifest", id))
+				}
+				printPodManifest(box, output)
+				continue
+			}
+
+			box = buildDevbox(state, id, box, rebuild)
 			err = box.Start()
 			exitOnError(err, 1, fmt.Sprintf("cannot start devbox %s", id))
 
+			if wait {
+				waitDevbox(id, box, timeout)
+			}
+			recordStarted(state, id, box)
+
+			// Credentials are injected again by shells, so a failure is not
+			// fatal.
+			if wait && len(box.Credentials) > 0 {
+				if err := box.InjectCredentials(); err != nil {
+					fmt.Printf("warning: cannot inject credentials in devbox %s: %v\n", id, err)
+				}
+			}
+
 			fmt.Println(fmt.Sprintf("devbox %s started", id))
 		}
 	},
@@ -47,4 +97,8 @@ Once started, devboxes can be used by opening a shell session with the shell com
 func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.Flags().StringP("id", "i", "", "Box id")
+	startCmd.Flags().Bool("wait", true, "Wait for devboxes to be ready")
+	startCmd.Flags().Duration("timeout", 0, "Maximum time to wait for devboxes to be ready (default is devbox start timeout)")
+	startCmd.Flags().Bool("rebuild", false, "Rebuild images of devboxes with a build configuration even if up to date")
+	startCmd.Flags().StringP("output", "o", "", "Manifest output format with --dry-run, yaml or json (Kubernetes devboxes only)")
 }
@@ -17,6 +17,8 @@ devboxes in Docker containers or a Kubernetes pods.
 
 If no ID arguments are provided, any set in the active devbox context will be used.
 
+Devboxes attached to existing workloads with the attach command are detached.
+
 Once stopped, any files copied over to that devbox will be lost.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Load state.
@@ -39,6 +41,8 @@ Once stopped, any files copied over to that devbox will be lost.`,
 			err = box.Stop()
 			exitOnError(err, 1, fmt.Sprintf("cannot stop devbox %s", id))
 
+			recordStopped(state, id, box)
+
 			fmt.Println(fmt.Sprintf("devbox %s stopped", id))
 		}
 	},
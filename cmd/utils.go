@@ -3,10 +3,21 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
 
+	"github.com/mojochao/devbox/internal/audit"
+	"github.com/mojochao/devbox/internal/config"
 	"github.com/mojochao/devbox/internal/devbox"
+	"github.com/mojochao/devbox/internal/util"
 )
 
 // ensureDevboxID ensures that a devbox ID to operate on is available
@@ -23,15 +34,200 @@ func ensureDevboxID(state devbox.State, id string) string {
 	return id
 }
 
-// exit exits the application with an exit code and a message.
+// getManifestTypes returns the manifest types selected by the --include and
+// --exclude flags of a command, in install order.
+func getManifestTypes(cmd *cobra.Command) []string {
+	includes, _ := cmd.Flags().GetStringSlice("include")
+	if len(includes) > 0 {
+		for _, include := range includes {
+			if !util.ContainsString(devbox.ManifestTypes, include) {
+				exit(1, fmt.Sprintf("invalid manifest type %s in --include flag", include))
+			}
+		}
+	} else {
+		includes = devbox.ManifestTypes
+	}
+	excludes, _ := cmd.Flags().GetStringSlice("exclude")
+
+	var manifestTypes []string
+	for _, manifestType := range devbox.ManifestTypes {
+		if !util.ContainsString(includes, manifestType) {
+			continue
+		}
+		if util.ContainsString(excludes, manifestType) {
+			continue
+		}
+		manifestTypes = append(manifestTypes, manifestType)
+	}
+	return manifestTypes
+}
+
+// parseObjectMounts returns the Secret or ConfigMap mounts of a flag.
+func parseObjectMounts(cmd *cobra.Command, flag string) []devbox.ObjectMount {
+	specs, _ := cmd.Flags().GetStringSlice(flag)
+	var mounts []devbox.ObjectMount
+	for _, spec := range specs {
+		mount, err := devbox.ParseObjectMount(spec)
+		exitOnError(err, 1, fmt.Sprintf("invalid --%s flag", flag))
+		mounts = append(mounts, mount)
+	}
+	return mounts
+}
+
+// setupDevbox sets up a devbox with manifest types, and records them in state
+// so that they can be setup again when the devbox is restarted or recreated.
+// The returned devbox is the updated devbox saved in state.
+func setupDevbox(state devbox.State, id string, box devbox.Box, manifestTypes []string, opts devbox.SetupOptions) devbox.Box {
+	for _, manifestType := range manifestTypes {
+		fmt.Printf("setting up devbox %s with %s config\n", id, manifestType)
+		err := box.Setup(manifestType, opts)
+		exitOnError(err, 1, fmt.Sprintf("cannot setup devbox %s with %s config", id, manifestType))
+	}
+	if len(box.SetupCommands) > 0 {
+		fmt.Printf("running setup commands in devbox %s\n", id)
+		err := box.RunSetupCommands()
+		exitOnError(err, 1, fmt.Sprintf("cannot run setup commands in devbox %s", id))
+	}
+	if box.ForwardGPGAgent {
+		fmt.Printf("importing GPG public keys in devbox %s\n", id)
+		err := box.ImportGPGPublicKeys()
+		exitOnError(err, 1, fmt.Sprintf("cannot import GPG public keys in devbox %s", id))
+	}
+	box.Runtime.SetupTypes = manifestTypes
+	if !dryRun {
+		err := state.UpdateDevbox(id, box)
+		exitOnError(err, 1, fmt.Sprintf("cannot save state to %s", stateFile))
+	}
+	return box
+}
+
+// buildDevbox builds the image of a devbox with a build configuration, unless
+// it is up to date and force is false, and returns the updated devbox saved in
+// state.
+func buildDevbox(state devbox.State, id string, box devbox.Box, force bool) devbox.Box {
+	if box.Build == nil {
+		return box
+	}
+	box, err := box.BuildImage(force)
+	exitOnError(err, 1, fmt.Sprintf("cannot build image of devbox %s", id))
+	if !dryRun {
+		err = state.UpdateDevbox(id, box)
+		exitOnError(err, 1, fmt.Sprintf("cannot save state to %s", stateFile))
+	}
+	return box
+}
+
+// recordStarted records the start of a devbox in state, and returns the
+// updated devbox saved in state.
+func recordStarted(state devbox.State, id string, box devbox.Box) devbox.Box {
+	if dryRun {
+		return box
+	}
+	box, err := box.Started(time.Now())
+	if err != nil {
+		fmt.Printf("warning: %v\n", err)
+	}
+	err = state.UpdateDevbox(id, box)
+	exitOnError(err, 1, fmt.Sprintf("cannot save state to %s", stateFile))
+	return box
+}
+
+// recordStopped records the stop of a devbox in state, and returns the
+// updated devbox saved in state. Stopped devboxes are no longer attached to
+// any workload.
+func recordStopped(state devbox.State, id string, box devbox.Box) devbox.Box {
+	if dryRun {
+		return box
+	}
+	box = box.Stopped(time.Now())
+	box.Attachment = nil
+	err := state.UpdateDevbox(id, box)
+	exitOnError(err, 1, fmt.Sprintf("cannot save state to %s", stateFile))
+	return box
+}
+
+// waitDevbox waits for a started devbox to be ready, up to timeout or the
+// start timeout of the devbox if zero.
+func waitDevbox(id string, box devbox.Box, timeout time.Duration) {
+	if dryRun {
+		return
+	}
+	if timeout == 0 {
+		timeout = box.StartTimeout()
+	}
+	fmt.Printf("waiting up to %s for devbox %s to be ready\n", timeout, id)
+	err := box.WaitReady(timeout)
+	exitOnError(err, 1, fmt.Sprintf("devbox %s not ready", id))
+}
+
+// exitHooks are the functions run by exit before the application exits, such
+// as to stop devboxes that must not outlive a command. They may be run on a
+// signal, so are guarded by exitHooksMutex.
+var (
+	exitHooks      []func()
+	exitHooksMutex sync.Mutex
+)
+
+// onExit registers a function run by exit, or runExitHooks.
+func onExit(hook func()) {
+	exitHooksMutex.Lock()
+	defer exitHooksMutex.Unlock()
+	exitHooks = append(exitHooks, hook)
+}
+
+// runExitHooks runs the registered exit hooks once, in reverse order.
+func runExitHooks() {
+	exitHooksMutex.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitHooksMutex.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i]()
+	}
+}
+
+// exitOnSignal exits the application once exit hooks have run when it is
+// hung up, terminated or interrupted. Interrupts are ignored while the
+// returned shellOpen flag is set, as they are handled by the interactive
+// shell the user is in.
+func exitOnSignal() (shellOpen *int32) {
+	shellOpen = new(int32)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGINT && atomic.LoadInt32(shellOpen) == 1 {
+				continue
+			}
+			exit(1, fmt.Sprintf("received %s signal", sig))
+		}
+	}()
+	return shellOpen
+}
+
+// exit exits the application with an exit code and a message, once any exit
+// hooks have run.
 func exit(exitCode int, msg string) {
+	runExitHooks()
+	auditMsg := ""
 	if exitCode != 0 {
+		auditMsg = msg
 		msg = fmt.Sprintf("error: %s", msg)
 	}
+	endAudit(exitCode, auditMsg)
 	fmt.Println(msg)
 	os.Exit(exitCode)
 }
 
+// endAudit appends the entry of the command being run to the audit log with
+// its exit status. Failures to do so are warned of, rather than failing the
+// command.
+func endAudit(exitCode int, msg string) {
+	if err := audit.End(config.AuditLog, exitCode, msg); err != nil {
+		fmt.Printf("warning: cannot append to audit log %s: %v\n", config.AuditLog, err)
+	}
+}
+
 // exitOnError exits the application on error with an exit code and a message.
 func exitOnError(err error, exitCode int, msg string) {
 	if err == nil {
@@ -40,14 +236,72 @@ func exitOnError(err error, exitCode int, msg string) {
 	exit(exitCode, fmt.Sprintf("%s: %v", msg, err))
 }
 
-func printBoxesTable(boxes devbox.Boxes) {
+// parseSince parses a relative duration, or an RFC3339 timestamp.
+func parseSince(since string) (time.Time, error) {
+	if duration, err := time.ParseDuration(since); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	return time.Parse(time.RFC3339, since)
+}
+
+// printAuditTable prints audit log entries as a table.
+func printAuditTable(entries []audit.Entry) {
+	if len(entries) == 0 {
+		return
+	}
+	tbl := table.New("time", "user", "command", "devboxes", "files", "status")
+	for _, entry := range entries {
+		var boxes []string
+		for _, box := range entry.Boxes {
+			boxes = append(boxes, fmt.Sprintf("%s@%s", box.ID, box))
+		}
+		command := strings.Join(append([]string{entry.Command}, entry.Args...), " ")
+		if entry.DryRun {
+			command += " (dry run)"
+		}
+		tbl.AddRow(entry.Time.Local().Format(time.RFC3339), entry.User, command, strings.Join(boxes, ","), len(entry.Files), entry.Status)
+	}
+	tbl.Print()
+}
+
+func printBoxesTable(boxes devbox.Boxes, wide bool) {
 	if len(boxes) == 0 {
 		return
 	}
-	tbl := table.New("id", "image", "user", "shell", "name", "namespace", "kubeconfig", "description")
+	headers := []interface{}{"id", "image", "user", "shell", "name", "namespace", "kubeconfig", "description"}
+	if wide {
+		headers = append(headers, "started", "stopped", "setup", "digest", "object")
+	}
+	tbl := table.New(headers...)
 	for id, box := range boxes {
-		tbl.AddRow(id, box.Image, box.User, box.Shell, box.Name, box.Namespace, box.Kubeconfig, box.Description)
+		row := []interface{}{id, box.Image, box.User, box.Shell, box.Name, box.Namespace, box.Kubeconfig, box.Description}
+		if wide {
+			runtime := box.Runtime
+			row = append(row, formatTime(runtime.StartedAt), formatTime(runtime.StoppedAt), strings.Join(runtime.SetupTypes, ","), runtime.ImageDigest, runtime.ObjectID)
+		}
+		tbl.AddRow(row...)
 	}
 	tbl.Print()
 
 }
+
+// formatTime formats an optional time for display in tables.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func printPodManifest(box devbox.Box, format string) {
+	manifest, err := box.PodManifest()
+	exitOnError(err, 1, fmt.Sprintf("cannot generate manifest for devbox %s", box.Name))
+	if format == "json" {
+		manifest, err = yaml.YAMLToJSON(manifest)
+		exitOnError(err, 1, fmt.Sprintf("cannot generate manifest for devbox %s", box.Name))
+		fmt.Println(string(manifest))
+		return
+	}
+	fmt.Println("---")
+	fmt.Print(string(manifest))
+}
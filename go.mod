@@ -7,4 +7,5 @@ require (
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/rodaine/table v1.0.1
 	github.com/spf13/cobra v1.1.3
+	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
 )
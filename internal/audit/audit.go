@@ -0,0 +1,260 @@
+// Package audit records devbox operations in a JSON lines audit log, so that
+// who started which devbox where, and what was copied to it, can be reviewed.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// DefaultLogFile defines the default location of the audit log file.
+const DefaultLogFile = "~/.devbox.audit.jsonl"
+
+// Entry is an audit log entry of a devbox command.
+type Entry struct {
+	// Time is when the command was run.
+	Time time.Time `json:"time"`
+
+	// User is the local user running the command.
+	User string `json:"user"`
+
+	// Command is the command run, such as devbox start.
+	Command string `json:"command"`
+
+	// Args contains the arguments of the command. Flags are omitted, as their
+	// values may be secrets.
+	Args []string `json:"args,omitempty"`
+
+	// DryRun indicates the command only previewed its operations.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Boxes contains the devboxes the command operated on.
+	Boxes []Box `json:"boxes,omitempty"`
+
+	// Files contains the local files copied to devboxes.
+	Files []string `json:"files,omitempty"`
+
+	// Status is the exit status of the command.
+	Status int `json:"status"`
+
+	// Error is the error message of a failed command.
+	Error string `json:"error,omitempty"`
+}
+
+// Box identifies a devbox and the runtime target it is run in.
+type Box struct {
+	// ID is the ID of the devbox in state.
+	ID string `json:"id"`
+
+	// Runtime is the runtime of the devbox, docker or kubernetes.
+	Runtime string `json:"runtime"`
+
+	// Name is the name of the container or pod of the devbox.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the pod of a Kubernetes devbox.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Kubeconfig is the kubeconfig of the cluster of a Kubernetes devbox.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+}
+
+// String returns the runtime target of a Box.
+func (box Box) String() string {
+	if box.Namespace == "" {
+		return fmt.Sprintf("%s/%s", box.Runtime, box.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", box.Runtime, box.Namespace, box.Name)
+}
+
+// current is the entry of the command being run, if any.
+var current *Entry
+
+// Begin starts the entry of a command being run.
+func Begin(command string, args []string, dryRun bool) {
+	name := ""
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	current = &Entry{
+		Time:    time.Now().UTC(),
+		User:    name,
+		Command: command,
+		Args:    args,
+		DryRun:  dryRun,
+	}
+}
+
+// RecordBox records a devbox operated on by the command being run.
+func RecordBox(box Box) {
+	if current == nil {
+		return
+	}
+	for _, b := range current.Boxes {
+		if b == box {
+			return
+		}
+	}
+	current.Boxes = append(current.Boxes, box)
+}
+
+// RecordFile records a local file copied to a devbox by the command being run.
+func RecordFile(path string) {
+	if current == nil {
+		return
+	}
+	current.Files = append(current.Files, path)
+}
+
+// End ends the entry of the command being run with its exit status and any
+// error message, and appends it to the audit log file. Nothing is logged if
+// no command is being run, or the path is empty.
+func End(path string, status int, message string) error {
+	if current == nil {
+		return nil
+	}
+	entry := *current
+	current = nil
+	if path == "" {
+		return nil
+	}
+	entry.Status = status
+	entry.Error = message
+	return Append(path, entry)
+}
+
+// Append appends an Entry to an audit log file, creating it if needed.
+func Append(path string, entry Entry) error {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(buf, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read returns the entries of an audit log file, which has none if it does
+// not exist.
+func Read(path string) ([]Entry, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid entry on line %d: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Filter selects audit log entries. Empty fields select all entries.
+type Filter struct {
+	// Box selects entries of commands operating on the devbox with this ID.
+	Box string
+
+	// User selects entries of commands run by this user.
+	User string
+
+	// Command selects entries of commands run with this subcommand, such as
+	// start or registry login.
+	Command string
+
+	// Since selects entries of commands run at or after this time.
+	Since time.Time
+
+	// Failed selects entries of commands that failed.
+	Failed bool
+}
+
+// Match tests if a Filter selects an Entry.
+func (filter Filter) Match(entry Entry) bool {
+	if filter.User != "" && entry.User != filter.User {
+		return false
+	}
+	if filter.Command != "" && !isSubcommand(entry.Command, filter.Command) {
+		return false
+	}
+	if !filter.Since.IsZero() && entry.Time.Before(filter.Since) {
+		return false
+	}
+	if filter.Failed && entry.Status == 0 {
+		return false
+	}
+	if filter.Box != "" {
+		for _, box := range entry.Boxes {
+			if box.ID == filter.Box {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// isSubcommand tests if a command was run with a subcommand, whose words
+// follow the root command name.
+func isSubcommand(command string, subcommand string) bool {
+	words := strings.Fields(command)
+	want := strings.Fields(subcommand)
+	if len(words) < len(want)+1 {
+		return false
+	}
+	for i, word := range want {
+		if words[i+1] != word {
+			return false
+		}
+	}
+	return true
+}
+
+// Query returns the entries of an audit log file selected by a Filter.
+func Query(path string, filter Filter) ([]Entry, error) {
+	entries, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	var selected []Entry
+	for _, entry := range entries {
+		if filter.Match(entry) {
+			selected = append(selected, entry)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilter_Match(t *testing.T) {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	entry := Entry{
+		Time:    now,
+		User:    "alice",
+		Command: "devbox registry login",
+		Boxes:   []Box{{ID: "eks", Runtime: "kubernetes", Name: "devbox-eks", Namespace: "dev"}},
+		Status:  1,
+	}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{
+			name:   "test happy path without filter",
+			filter: Filter{},
+			want:   true,
+		},
+		{
+			name:   "test happy path with all filters",
+			filter: Filter{Box: "eks", User: "alice", Command: "registry login", Since: now, Failed: true},
+			want:   true,
+		},
+		{
+			name:   "test happy path with parent command",
+			filter: Filter{Command: "registry"},
+			want:   true,
+		},
+		{
+			name:   "test crappy other box",
+			filter: Filter{Box: "local"},
+			want:   false,
+		},
+		{
+			name:   "test crappy other user",
+			filter: Filter{User: "bob"},
+			want:   false,
+		},
+		{
+			name:   "test crappy other command",
+			filter: Filter{Command: "login"},
+			want:   false,
+		},
+		{
+			name:   "test crappy newer since",
+			filter: Filter{Since: now.Add(time.Second)},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(entry); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devbox-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.jsonl")
+
+	Begin("devbox setup", []string{"eks"}, false)
+	box := Box{ID: "eks", Runtime: "kubernetes", Name: "devbox-eks", Namespace: "dev"}
+	RecordBox(box)
+	RecordBox(box)
+	RecordFile("/home/alice/.gitconfig")
+	if err := End(path, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	Begin("devbox start", []string{"local"}, true)
+	if err := End(path, 1, "cannot start devbox local"); err != nil {
+		t.Fatal(err)
+	}
+	RecordFile("/home/alice/.zshrc")
+	if err := End(path, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Read() got %d entries, want 2", len(entries))
+	}
+	if !reflect.DeepEqual(entries[0].Boxes, []Box{box}) {
+		t.Errorf("Read() boxes = %v, want %v", entries[0].Boxes, []Box{box})
+	}
+	if !reflect.DeepEqual(entries[0].Files, []string{"/home/alice/.gitconfig"}) {
+		t.Errorf("Read() files = %v, want %v", entries[0].Files, []string{"/home/alice/.gitconfig"})
+	}
+	if entries[1].Status != 1 || entries[1].Error != "cannot start devbox local" || !entries[1].DryRun {
+		t.Errorf("Read() got = %+v, want failed dry run", entries[1])
+	}
+
+	failed, err := Query(path, Filter{Failed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].Command != "devbox start" {
+		t.Errorf("Query() got = %+v, want devbox start entry", failed)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("audit log mode = %v, want 0600", info.Mode().Perm())
+	}
+}
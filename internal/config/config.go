@@ -1,6 +1,9 @@
 // Package config contains global config state used by other packages.
 package config
 
+// AuditLog is the location of the audit log file, which is disabled if empty.
+var AuditLog string
+
 // DryRun indicates if commands are to be shown only and not executed.
 var DryRun bool
 
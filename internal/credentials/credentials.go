@@ -0,0 +1,113 @@
+// Package credentials resolves short-lived cloud credentials from the local
+// AWS, Google Cloud and Azure CLIs for injection in devboxes.
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// Providers contains the supported credentials providers.
+var Providers = []string{"aws", "gcp", "azure"}
+
+// Source identifies the credentials of a cloud provider to resolve.
+type Source struct {
+	// Provider is the cloud provider of the credentials, one of aws, gcp or
+	// azure.
+	Provider string `yaml:"provider"`
+
+	// Profile is the AWS profile, gcloud configuration or Azure subscription
+	// of the credentials, if not the default.
+	Profile string `yaml:"profile,omitempty"`
+}
+
+// ParseSource parses a Source in PROVIDER[:PROFILE] format.
+func ParseSource(s string) (Source, error) {
+	parts := strings.SplitN(s, ":", 2)
+	source := Source{Provider: parts[0]}
+	if len(parts) == 2 {
+		source.Profile = parts[1]
+		if source.Profile == "" {
+			return source, fmt.Errorf("invalid credentials %q, profile cannot be empty", s)
+		}
+	}
+	return source, source.Validate()
+}
+
+// Validate ensures a Source is correctly configured.
+func (source Source) Validate() error {
+	if !util.ContainsString(Providers, source.Provider) {
+		return fmt.Errorf("invalid credentials provider %q, want one of %s", source.Provider, strings.Join(Providers, ", "))
+	}
+	return nil
+}
+
+// String returns a Source in PROVIDER[:PROFILE] format.
+func (source Source) String() string {
+	if source.Profile == "" {
+		return source.Provider
+	}
+	return fmt.Sprintf("%s:%s", source.Provider, source.Profile)
+}
+
+// Provider resolves short-lived credentials from the CLI of a cloud provider.
+type Provider interface {
+	// Env returns the environment variables providing the credentials.
+	Env() (map[string]string, error)
+}
+
+// NewProvider returns the Provider of a Source.
+func NewProvider(source Source) (Provider, error) {
+	switch source.Provider {
+	case "aws":
+		return awsProvider{profile: source.Profile}, nil
+	case "gcp":
+		return gcpProvider{configuration: source.Profile}, nil
+	case "azure":
+		return azureProvider{subscription: source.Profile}, nil
+	}
+	return nil, source.Validate()
+}
+
+// Resolve resolves the credentials of sources and returns the environment
+// variables providing them.
+func Resolve(sources []Source) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, source := range sources {
+		provider, err := NewProvider(source)
+		if err != nil {
+			return nil, err
+		}
+		env, err := provider.Env()
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %s credentials: %v", source, err)
+		}
+		for name, value := range env {
+			vars[name] = value
+		}
+	}
+	return vars, nil
+}
+
+// EnvFile returns a shell script exporting environment variables, sorted by
+// name.
+func EnvFile(vars map[string]string) []byte {
+	var names []string
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		value := strings.Replace(vars[name], "'", `'\''`, -1)
+		fmt.Fprintf(&b, "export %s='%s'\n", name, value)
+	}
+	return []byte(b.String())
+}
+
+// errNoCredentials is returned when a CLI provides no credentials.
+var errNoCredentials = errors.New("no credentials found, log in with the provider CLI")
@@ -0,0 +1,146 @@
+package credentials
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Source
+		wantErr bool
+	}{
+		{
+			name:  "test happy path",
+			input: "aws",
+			want:  Source{Provider: "aws"},
+		},
+		{
+			name:  "test happy path with profile",
+			input: "gcp:work",
+			want:  Source{Provider: "gcp", Profile: "work"},
+		},
+		{
+			name:    "test crappy unknown provider",
+			input:   "oci",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy empty profile",
+			input:   "azure:",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSource(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseSource() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseSource() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseAWSCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "test happy path",
+			input: `{"Version": 1, "AccessKeyId": "ASIA1", "SecretAccessKey": "secret", "SessionToken": "token", "Expiration": "2030-01-01T00:00:00Z"}`,
+			want: map[string]string{
+				"AWS_ACCESS_KEY_ID":         "ASIA1",
+				"AWS_SECRET_ACCESS_KEY":     "secret",
+				"AWS_SESSION_TOKEN":         "token",
+				"AWS_CREDENTIAL_EXPIRATION": "2030-01-01T00:00:00Z",
+			},
+		},
+		{
+			name:  "test happy path long-lived",
+			input: `{"Version": 1, "AccessKeyId": "AKIA1", "SecretAccessKey": "secret"}`,
+			want: map[string]string{
+				"AWS_ACCESS_KEY_ID":     "AKIA1",
+				"AWS_SECRET_ACCESS_KEY": "secret",
+			},
+		},
+		{
+			name:    "test crappy missing secret",
+			input:   `{"Version": 1, "AccessKeyId": "AKIA1"}`,
+			wantErr: true,
+		},
+		{
+			name:    "test crappy invalid json",
+			input:   `AccessKeyId=AKIA1`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAWSCredentials([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseAWSCredentials() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAWSCredentials() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseAzureAccessToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "test happy path",
+			input: `{"accessToken": "token", "expiresOn": "2030-01-01 00:00:00.000000", "subscription": "sub", "tenant": "tenant", "tokenType": "Bearer"}`,
+			want: map[string]string{
+				"AZURE_ACCESS_TOKEN":            "token",
+				"AZURE_ACCESS_TOKEN_EXPIRES_ON": "2030-01-01 00:00:00.000000",
+				"AZURE_SUBSCRIPTION_ID":         "sub",
+				"AZURE_TENANT_ID":               "tenant",
+			},
+		},
+		{
+			name:    "test crappy missing token",
+			input:   `{"subscription": "sub"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAzureAccessToken([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseAzureAccessToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAzureAccessToken() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvFile(t *testing.T) {
+	vars := map[string]string{
+		"B": "it's",
+		"A": "plain",
+	}
+	want := "export A='plain'\nexport B='it'\\''s'\n"
+	if got := string(EnvFile(vars)); got != want {
+		t.Errorf("EnvFile() got = %q, want %q", got, want)
+	}
+}
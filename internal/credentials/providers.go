@@ -0,0 +1,166 @@
+package credentials
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// awsProvider resolves AWS credentials of a profile with the AWS CLI, which
+// supports credential_process, SSO and assumed role profiles.
+type awsProvider struct {
+	profile string
+}
+
+// awsProcessCredentials is the output of the AWS CLI export-credentials
+// command in credential_process format.
+type awsProcessCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+// Env returns the AWS credentials and region environment variables.
+func (p awsProvider) Env() (map[string]string, error) {
+	out, err := util.OutputCommand("aws", p.args("configure", "export-credentials", "--format", "process")...)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := parseAWSCredentials(out)
+	if err != nil {
+		return nil, err
+	}
+	// The region is optional, and the command fails if it is not set.
+	if out, err := util.OutputCommand("aws", p.args("configure", "get", "region")...); err == nil {
+		if region := strings.TrimSpace(string(out)); region != "" {
+			vars["AWS_REGION"] = region
+			vars["AWS_DEFAULT_REGION"] = region
+		}
+	}
+	return vars, nil
+}
+
+// args returns AWS CLI args selecting the profile of the provider.
+func (p awsProvider) args(args ...string) []string {
+	if p.profile != "" {
+		args = append(args, "--profile", p.profile)
+	}
+	return args
+}
+
+// parseAWSCredentials parses AWS credentials in credential_process format into
+// environment variables.
+func parseAWSCredentials(buf []byte) (map[string]string, error) {
+	var creds awsProcessCredentials
+	if err := json.Unmarshal(buf, &creds); err != nil {
+		return nil, fmt.Errorf("cannot parse AWS credentials: %v", err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, errNoCredentials
+	}
+	vars := map[string]string{
+		"AWS_ACCESS_KEY_ID":     creds.AccessKeyID,
+		"AWS_SECRET_ACCESS_KEY": creds.SecretAccessKey,
+	}
+	if creds.SessionToken != "" {
+		vars["AWS_SESSION_TOKEN"] = creds.SessionToken
+	}
+	if creds.Expiration != "" {
+		vars["AWS_CREDENTIAL_EXPIRATION"] = creds.Expiration
+	}
+	return vars, nil
+}
+
+// gcpProvider resolves a Google Cloud access token of the application default
+// credentials with the gcloud CLI.
+type gcpProvider struct {
+	configuration string
+}
+
+// Env returns the Google Cloud access token and project environment
+// variables, used by the gcloud CLI and Terraform among others.
+func (p gcpProvider) Env() (map[string]string, error) {
+	out, err := util.OutputCommand("gcloud", p.args("auth", "application-default", "print-access-token")...)
+	if err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return nil, errNoCredentials
+	}
+	vars := map[string]string{
+		"CLOUDSDK_AUTH_ACCESS_TOKEN": token,
+		"GOOGLE_OAUTH_ACCESS_TOKEN":  token,
+	}
+	if out, err := util.OutputCommand("gcloud", p.args("config", "get-value", "project")...); err == nil {
+		if project := strings.TrimSpace(string(out)); project != "" {
+			vars["CLOUDSDK_CORE_PROJECT"] = project
+			vars["GOOGLE_CLOUD_PROJECT"] = project
+		}
+	}
+	return vars, nil
+}
+
+// args returns gcloud CLI args selecting the configuration of the provider.
+func (p gcpProvider) args(args ...string) []string {
+	if p.configuration != "" {
+		args = append(args, "--configuration", p.configuration)
+	}
+	return args
+}
+
+// azureProvider resolves an Azure access token of a subscription with the
+// Azure CLI.
+type azureProvider struct {
+	subscription string
+}
+
+// azureAccessToken is the output of the Azure CLI get-access-token command.
+type azureAccessToken struct {
+	AccessToken  string `json:"accessToken"`
+	ExpiresOn    string `json:"expiresOn"`
+	Subscription string `json:"subscription"`
+	Tenant       string `json:"tenant"`
+}
+
+// Env returns the Azure access token, subscription and tenant environment
+// variables.
+func (p azureProvider) Env() (map[string]string, error) {
+	args := []string{"account", "get-access-token", "--output", "json"}
+	if p.subscription != "" {
+		args = append(args, "--subscription", p.subscription)
+	}
+	out, err := util.OutputCommand("az", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseAzureAccessToken(out)
+}
+
+// parseAzureAccessToken parses an Azure access token into environment
+// variables.
+func parseAzureAccessToken(buf []byte) (map[string]string, error) {
+	var token azureAccessToken
+	if err := json.Unmarshal(buf, &token); err != nil {
+		return nil, fmt.Errorf("cannot parse Azure access token: %v", err)
+	}
+	if token.AccessToken == "" {
+		return nil, errNoCredentials
+	}
+	vars := map[string]string{
+		"AZURE_ACCESS_TOKEN": token.AccessToken,
+	}
+	if token.ExpiresOn != "" {
+		vars["AZURE_ACCESS_TOKEN_EXPIRES_ON"] = token.ExpiresOn
+	}
+	if token.Subscription != "" {
+		vars["AZURE_SUBSCRIPTION_ID"] = token.Subscription
+	}
+	if token.Tenant != "" {
+		vars["AZURE_TENANT_ID"] = token.Tenant
+	}
+	return vars, nil
+}
@@ -0,0 +1,121 @@
+package devbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+)
+
+// sshAgentSocket is the path of the SSH agent socket in a Box forwarding the
+// local SSH agent.
+const sshAgentSocket = "/tmp/devbox-ssh-agent.sock"
+
+// dockerDesktopSSHAgentSocket is the SSH agent socket Docker Desktop for Mac
+// provides to containers, as host sockets cannot be bind mounted.
+const dockerDesktopSSHAgentSocket = "/run/host-services/ssh-auth.sock"
+
+// maxAgentMessageSize is the maximum size of an SSH agent message relayed, as
+// defined by the SSH agent protocol.
+const maxAgentMessageSize = 256 * 1024
+
+// localSSHAgentSocket returns the path to the socket of the local SSH agent.
+func localSSHAgentSocket() (string, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return "", errors.New("no SSH agent is running, SSH_AUTH_SOCK is not set")
+	}
+	return socket, nil
+}
+
+// sshAgentDockerArgs returns the docker run arguments bind mounting the local
+// SSH agent socket in a Docker Box forwarding it.
+func (box Box) sshAgentDockerArgs() ([]string, error) {
+	if !box.ForwardSSHAgent {
+		return nil, nil
+	}
+	source := dockerDesktopSSHAgentSocket
+	if runtime.GOOS != "darwin" {
+		var err error
+		if source, err = localSSHAgentSocket(); err != nil {
+			return nil, err
+		}
+	}
+	return []string{
+		fmt.Sprintf("--mount=type=bind,source=%s,target=%s", source, sshAgentSocket),
+		fmt.Sprintf("--env=SSH_AUTH_SOCK=%s", sshAgentSocket),
+	}, nil
+}
+
+// relayAgentMessages relays SSH agent request messages read from requests to
+// an agent, and writes its response messages to responses, until requests are
+// exhausted. The agent connection is dialed when first needed, and dialed
+// again if it fails.
+func relayAgentMessages(requests io.Reader, responses io.Writer, dial func() (net.Conn, error)) error {
+	var agent net.Conn
+	defer func() {
+		if agent != nil {
+			agent.Close()
+		}
+	}()
+	for {
+		request, err := readAgentMessage(requests)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if agent == nil {
+			if agent, err = dial(); err != nil {
+				return fmt.Errorf("cannot connect to SSH agent: %v", err)
+			}
+		}
+		response, err := exchangeAgentMessage(agent, request)
+		if err != nil {
+			// The agent may have been restarted, so try again once with a
+			// new connection.
+			agent.Close()
+			if agent, err = dial(); err != nil {
+				return fmt.Errorf("cannot connect to SSH agent: %v", err)
+			}
+			if response, err = exchangeAgentMessage(agent, request); err != nil {
+				return err
+			}
+		}
+		if _, err := responses.Write(response); err != nil {
+			return err
+		}
+	}
+}
+
+// exchangeAgentMessage writes a request message to an agent and returns its
+// response message.
+func exchangeAgentMessage(agent net.Conn, request []byte) ([]byte, error) {
+	if _, err := agent.Write(request); err != nil {
+		return nil, err
+	}
+	return readAgentMessage(agent)
+}
+
+// readAgentMessage reads an SSH agent message, which is a 4 byte big endian
+// length followed by its contents, and returns it including its length.
+func readAgentMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length > maxAgentMessageSize {
+		return nil, fmt.Errorf("SSH agent message of %d bytes too large", length)
+	}
+	message := make([]byte, 4+length)
+	copy(message, header)
+	if _, err := io.ReadFull(r, message[4:]); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
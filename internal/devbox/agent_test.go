@@ -0,0 +1,103 @@
+package devbox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// agentMessage returns an SSH agent message with contents.
+func agentMessage(contents string) []byte {
+	message := make([]byte, 4+len(contents))
+	binary.BigEndian.PutUint32(message, uint32(len(contents)))
+	copy(message[4:], contents)
+	return message
+}
+
+// dialReversingAgent dials a fake SSH agent, which responds to each request
+// with its reversed contents.
+func dialReversingAgent() (net.Conn, error) {
+	client, agent := net.Pipe()
+	go func() {
+		defer agent.Close()
+		for {
+			request, err := readAgentMessage(agent)
+			if err != nil {
+				return
+			}
+			contents := request[4:]
+			reversed := make([]byte, len(contents))
+			for i, b := range contents {
+				reversed[len(contents)-1-i] = b
+			}
+			if _, err := agent.Write(agentMessage(string(reversed))); err != nil {
+				return
+			}
+		}
+	}()
+	return client, nil
+}
+
+func Test_relayAgentMessages(t *testing.T) {
+	var dials int
+	dial := func() (net.Conn, error) {
+		dials++
+		return dialReversingAgent()
+	}
+
+	var requests bytes.Buffer
+	requests.Write(agentMessage("\x0bidentities"))
+	requests.Write(agentMessage("\x0dsign"))
+	var responses bytes.Buffer
+	if err := relayAgentMessages(&requests, &responses, dial); err != nil {
+		t.Fatalf("relayAgentMessages() error = %v", err)
+	}
+
+	var want bytes.Buffer
+	want.Write(agentMessage("seititnedi\x0b"))
+	want.Write(agentMessage("ngis\x0d"))
+	if !bytes.Equal(responses.Bytes(), want.Bytes()) {
+		t.Errorf("relayAgentMessages() responses = %q, want %q", responses.Bytes(), want.Bytes())
+	}
+	if dials != 1 {
+		t.Errorf("relayAgentMessages() dialed %d times, want 1", dials)
+	}
+}
+
+func Test_readAgentMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "test happy path",
+			input: agentMessage("\x0b"),
+			want:  agentMessage("\x0b"),
+		},
+		{
+			name:    "test crappy truncated message",
+			input:   agentMessage("\x0bidentities")[:6],
+			wantErr: true,
+		},
+		{
+			name:    "test crappy oversized message",
+			input:   []byte{0xff, 0xff, 0xff, 0xff},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAgentMessage(bytes.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readAgentMessage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("readAgentMessage() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
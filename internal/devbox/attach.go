@@ -0,0 +1,296 @@
+package devbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// AttachMode identifies how a Box is attached to an existing workload.
+type AttachMode = string
+
+const (
+	// AttachEphemeral attaches a Kubernetes Box as an ephemeral container in
+	// an existing pod.
+	AttachEphemeral AttachMode = "ephemeral"
+
+	// AttachContainer attaches a Docker Box to the network and process
+	// namespaces of an existing container.
+	AttachContainer AttachMode = "container"
+
+	// AttachSidecar attaches a Kubernetes Box as a sidecar container patched
+	// into the pod template of an existing deployment or statefulset.
+	AttachSidecar AttachMode = "sidecar"
+)
+
+// targetRoot is the path at which the filesystem of a target container is
+// linked in a Docker Box attached to it.
+const targetRoot = "/target"
+
+// Attachment describes the existing workload a Box is attached to.
+type Attachment struct {
+	// Mode is how the devbox is attached.
+	Mode AttachMode `yaml:"mode"`
+
+	// Pod is the name of the pod hosting the devbox container, if any.
+	Pod string `yaml:"pod"`
+
+	// Container is the name of the target container whose namespaces or
+	// volumes are shared with the devbox container.
+	Container string `yaml:"container"`
+
+	// Workload is the kind/name of the workload patched with the devbox
+	// sidecar container, if any.
+	Workload string `yaml:"workload"`
+
+	// OriginalTemplate is the JSON pod template of the workload before it was
+	// patched, restored when the devbox is stopped.
+	OriginalTemplate string `yaml:"originalTemplate"`
+}
+
+// String returns a description of the Attachment target.
+func (attachment Attachment) String() string {
+	target := attachment.Pod
+	if attachment.Workload != "" {
+		target = attachment.Workload
+	}
+	if attachment.Container != "" {
+		if target != "" {
+			target += "/"
+		}
+		target += attachment.Container
+	}
+	return fmt.Sprintf("%s %s", attachment.Mode, target)
+}
+
+// ephemeralPidFile is the file the ephemeral container keep-alive writes its
+// process ID to. Ephemeral containers cannot be removed from a pod, and as
+// they share the process namespace of their target, PID 1 is not theirs to
+// signal, so this is used to stop them.
+const ephemeralPidFile = "/tmp/devbox.pid"
+
+// ephemeralKeepAlive is the command of ephemeral devbox containers.
+var ephemeralKeepAlive = []string{
+	"/bin/sh", "-c",
+	fmt.Sprintf("echo $$ > %s; trap 'exit 0' TERM INT; while true; do sleep 3600 & wait $!; done", ephemeralPidFile),
+}
+
+// AttachPod attaches a Kubernetes Box to an existing pod as an ephemeral
+// container sharing the process namespace of its target container, and waits
+// until it is running. If container is empty, the first container of the pod
+// is targeted. The returned Box is the attached Box to save in state.
+func (box Box) AttachPod(pod string, container string, timeout time.Duration) (Box, error) {
+	if box.Namespace == "" {
+		return box, errors.New("only Kubernetes devboxes can be attached to pods")
+	}
+	if box.Attachment != nil {
+		return box, fmt.Errorf("devbox %s is already attached to %s", box.Name, box.Attachment)
+	}
+	box, err := box.verifyImage()
+	if err != nil {
+		return box, err
+	}
+	if container == "" && !config.DryRun {
+		out, err := util.OutputCommand("kubectl", box.kubectlArgs("get", "pod", pod, "-o", "jsonpath={.spec.containers[0].name}")...)
+		if err != nil {
+			return box, fmt.Errorf("cannot get containers of pod %s: %v", pod, err)
+		}
+		container = strings.TrimSpace(string(out))
+	}
+
+	if config.Verbose {
+		fmt.Printf("msg: attaching devbox %s to pod %s in %s namespace in cluster with %s kubeconfig\n", box.Name, pod, box.Namespace, box.Kubeconfig)
+	}
+	if err := util.ExecCommand("kubectl", box.attachPodArgs(pod, container)...); err != nil {
+		return box, err
+	}
+
+	box.Attachment = &Attachment{
+		Mode:      AttachEphemeral,
+		Pod:       pod,
+		Container: container,
+	}
+	if config.DryRun {
+		return box, nil
+	}
+	return box, box.waitEphemeralRunning(time.Now().Add(timeout))
+}
+
+// attachPodArgs returns the kubectl debug arguments adding a Box to a pod as
+// an ephemeral container targeting one of its containers, if provided.
+func (box Box) attachPodArgs(pod string, container string) []string {
+	args := []string{"debug", pod, fmt.Sprintf("--image=%s", box.imageRef()), fmt.Sprintf("--container=%s", box.Name)}
+	if container != "" {
+		args = append(args, fmt.Sprintf("--target=%s", container))
+	}
+	// Ephemeral containers comply with the security profile of the devbox
+	// with the kubectl debug profile of the same name.
+	if box.SecurityProfile == SecurityRestricted || box.SecurityProfile == SecurityBaseline {
+		args = append(args, fmt.Sprintf("--profile=%s", box.SecurityProfile))
+	}
+	args = append(args, "--")
+	args = append(args, ephemeralKeepAlive...)
+	return box.kubectlArgs(args...)
+}
+
+// AttachContainer starts a Docker Box sharing the network and process
+// namespaces of an existing container, and waits until it is running. If
+// linkRoot is true, the filesystem of the target container is linked at
+// /target in the devbox. The returned Box is the attached Box to save in
+// state.
+func (box Box) AttachContainer(container string, linkRoot bool, timeout time.Duration) (Box, error) {
+	if box.Namespace != "" {
+		return box, errors.New("only Docker devboxes can be attached to containers")
+	}
+	if box.Attachment != nil {
+		return box, fmt.Errorf("devbox %s is already attached to %s", box.Name, box.Attachment)
+	}
+	box, err := box.verifyImage()
+	if err != nil {
+		return box, err
+	}
+
+	if config.Verbose {
+		fmt.Printf("msg: attaching devbox %s to container %s in docker\n", box.Name, container)
+	}
+	args, err := box.attachContainerArgs(container)
+	if err != nil {
+		return box, err
+	}
+	if err := util.ExecCommand("docker", args...); err != nil {
+		return box, err
+	}
+
+	box.Attachment = &Attachment{
+		Mode:      AttachContainer,
+		Container: container,
+	}
+	if !config.DryRun {
+		if err := box.waitContainerRunning(time.Now().Add(timeout)); err != nil {
+			return box, err
+		}
+	}
+	if linkRoot {
+		// The target container entrypoint is PID 1 in its process namespace
+		// shared with the devbox, so its root filesystem is /proc/1/root.
+		// The link is created as root, as the devbox user may not be.
+		link := fmt.Sprintf("ln -sfn /proc/1/root %s", targetRoot)
+		if err := util.ExecCommand("docker", "exec", "--user=0", box.Name, "sh", "-c", link); err != nil {
+			return box, fmt.Errorf("cannot link target container filesystem at %s: %v", targetRoot, err)
+		}
+	}
+	return box, nil
+}
+
+// attachContainerArgs returns the docker run arguments of a Box attached to
+// a container, sharing its network and process namespaces and allowed to
+// trace its processes.
+func (box Box) attachContainerArgs(container string) ([]string, error) {
+	keepAlive := box.KeepAlive
+	if keepAlive == nil {
+		keepAlive = &DefaultKeepAlive
+	}
+	agentArgs, err := box.sshAgentDockerArgs()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{
+		"run", "--detach", "--name", box.Name, "--rm",
+		fmt.Sprintf("--network=container:%s", container),
+		fmt.Sprintf("--pid=container:%s", container),
+		"--cap-add=SYS_PTRACE",
+	}
+	args = append(args, agentArgs...)
+	args = append(args, box.Resources.dockerArgs()...)
+	args = append(args, keepAlive.dockerArgs()...)
+	args = append(args, box.imageRef())
+	args = append(args, keepAlive.dockerCommand()...)
+	return args, nil
+}
+
+// ephemeralStatus is the subset of Kubernetes pod status used to wait for
+// an ephemeral container.
+type ephemeralStatus struct {
+	Status struct {
+		EphemeralContainerStatuses []struct {
+			Name  string `json:"name"`
+			State struct {
+				Waiting *struct {
+					Reason  string `json:"reason"`
+					Message string `json:"message"`
+				} `json:"waiting"`
+				Running    *struct{} `json:"running"`
+				Terminated *struct {
+					Reason   string `json:"reason"`
+					ExitCode int    `json:"exitCode"`
+				} `json:"terminated"`
+			} `json:"state"`
+		} `json:"ephemeralContainerStatuses"`
+	} `json:"status"`
+}
+
+// ephemeralRunning returns whether the ephemeral container of a Box is running
+// in the JSON status of its pod, or an error if it has failed to.
+func (box Box) ephemeralRunning(pod string, out []byte) (bool, error) {
+	var status ephemeralStatus
+	if err := json.Unmarshal(out, &status); err != nil {
+		return false, err
+	}
+	for _, container := range status.Status.EphemeralContainerStatuses {
+		if container.Name != box.Name {
+			continue
+		}
+		if container.State.Running != nil {
+			return true, nil
+		}
+		if terminated := container.State.Terminated; terminated != nil {
+			return false, fmt.Errorf("devbox container %s in pod %s terminated with code %d: %s", box.Name, pod, terminated.ExitCode, terminated.Reason)
+		}
+		if waiting := container.State.Waiting; waiting != nil {
+			if hint, ok := containerFailureReasons[waiting.Reason]; ok {
+				return false, fmt.Errorf("devbox container %s in pod %s is in %s: %s: %s", box.Name, pod, waiting.Reason, hint, waiting.Message)
+			}
+		}
+	}
+	return false, nil
+}
+
+func (box Box) waitEphemeralRunning(deadline time.Time) error {
+	pod := box.podName()
+	for {
+		out, err := util.OutputCommand("kubectl", box.kubectlArgs("get", "pod", pod, "-o", "json")...)
+		if err != nil {
+			return err
+		}
+		if running, err := box.ephemeralRunning(pod, out); running || err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for devbox container %s in pod %s to run", box.Name, pod)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// detach stops an attached Box.
+func (box Box) detach() error {
+	switch box.Attachment.Mode {
+	case AttachEphemeral:
+		// Ephemeral containers cannot be removed, only stopped. They remain
+		// listed in the pod status until the pod is deleted.
+		args := box.execSubcommand(false, "sh", "-c", fmt.Sprintf("kill $(cat %s)", ephemeralPidFile))
+		return box.execCommand(args)
+	case AttachContainer:
+		// Attached Docker devboxes are run with --rm, so stopping them
+		// removes them.
+		return util.ExecCommand("docker", "stop", box.Name)
+	case AttachSidecar:
+		return box.restoreWorkload()
+	}
+	return fmt.Errorf("unknown attach mode %q", box.Attachment.Mode)
+}
@@ -0,0 +1,163 @@
+package devbox
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBox_attachContainerArgs(t *testing.T) {
+	defer os.Setenv("SSH_AUTH_SOCK", os.Getenv("SSH_AUTH_SOCK"))
+	tests := []struct {
+		name    string
+		box     Box
+		sshAuth string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "test happy path default keep-alive",
+			box:  Box{Name: "devbox", Image: "ubuntu"},
+			want: []string{
+				"run", "--detach", "--name", "devbox", "--rm",
+				"--network=container:web", "--pid=container:web", "--cap-add=SYS_PTRACE",
+				"--ulimit=nofile=90000:90000", "--init", "--entrypoint", "/bin/sh", "ubuntu",
+				"-c", DefaultKeepAlive.Args[0],
+			},
+		},
+		{
+			name: "test happy path pinned image, keep-alive and forwarded SSH agent",
+			box: Box{
+				Name:            "devbox",
+				Image:           "ubuntu:22.04",
+				Digest:          "sha256:abc",
+				KeepAlive:       &KeepAlive{Command: []string{"sleep"}, Args: []string{"infinity"}},
+				ForwardSSHAgent: true,
+			},
+			sshAuth: "/run/agent.sock",
+			want: []string{
+				"run", "--detach", "--name", "devbox", "--rm",
+				"--network=container:web", "--pid=container:web", "--cap-add=SYS_PTRACE",
+				"--mount=type=bind,source=/run/agent.sock,target=/tmp/devbox-ssh-agent.sock",
+				"--env=SSH_AUTH_SOCK=/tmp/devbox-ssh-agent.sock",
+				"--ulimit=nofile=90000:90000", "--init", "--entrypoint", "sleep", "ubuntu@sha256:abc", "infinity",
+			},
+		},
+		{
+			name:    "test crappy forwarded SSH agent not running",
+			box:     Box{Name: "devbox", Image: "ubuntu", ForwardSSHAgent: true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("SSH_AUTH_SOCK", tt.sshAuth)
+			got, err := tt.box.attachContainerArgs("web")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("attachContainerArgs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attachContainerArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_attachPodArgs(t *testing.T) {
+	box := Box{Name: "devbox", Image: "ubuntu", Namespace: "dev", Kubeconfig: "/kube/config"}
+	pinned := box
+	pinned.Digest = "sha256:abc"
+	pinned.SecurityProfile = SecurityRestricted
+	privileged := box
+	privileged.SecurityProfile = SecurityPrivileged
+	keepAlive := append([]string{"--"}, ephemeralKeepAlive...)
+	tests := []struct {
+		name      string
+		box       Box
+		container string
+		want      []string
+	}{
+		{
+			name:      "test happy path target container",
+			box:       box,
+			container: "app",
+			want:      append([]string{"--kubeconfig=/kube/config", "--namespace=dev", "debug", "web", "--image=ubuntu", "--container=devbox", "--target=app"}, keepAlive...),
+		},
+		{
+			name: "test happy path no target container",
+			box:  box,
+			want: append([]string{"--kubeconfig=/kube/config", "--namespace=dev", "debug", "web", "--image=ubuntu", "--container=devbox"}, keepAlive...),
+		},
+		{
+			name:      "test happy path pinned image and restricted profile",
+			box:       pinned,
+			container: "app",
+			want:      append([]string{"--kubeconfig=/kube/config", "--namespace=dev", "debug", "web", "--image=ubuntu@sha256:abc", "--container=devbox", "--target=app", "--profile=restricted"}, keepAlive...),
+		},
+		{
+			name:      "test happy path privileged profile omitted",
+			box:       privileged,
+			container: "app",
+			want:      append([]string{"--kubeconfig=/kube/config", "--namespace=dev", "debug", "web", "--image=ubuntu", "--container=devbox", "--target=app"}, keepAlive...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.box.attachPodArgs("web", tt.container); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attachPodArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_ephemeralRunning(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "test happy path running",
+			status: `{"status":{"ephemeralContainerStatuses":[{"name":"other","state":{"terminated":{"reason":"Error","exitCode":1}}},{"name":"devbox","state":{"running":{}}}]}}`,
+			want:   true,
+		},
+		{
+			name:   "test happy path not yet listed",
+			status: `{"status":{}}`,
+		},
+		{
+			name:   "test happy path container creating",
+			status: `{"status":{"ephemeralContainerStatuses":[{"name":"devbox","state":{"waiting":{"reason":"ContainerCreating"}}}]}}`,
+		},
+		{
+			name:    "test crappy image pull back-off",
+			status:  `{"status":{"ephemeralContainerStatuses":[{"name":"devbox","state":{"waiting":{"reason":"ImagePullBackOff","message":"not found"}}}]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "test crappy terminated",
+			status:  `{"status":{"ephemeralContainerStatuses":[{"name":"devbox","state":{"terminated":{"reason":"Error","exitCode":127}}}]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "test crappy invalid status",
+			status:  `not json`,
+			wantErr: true,
+		},
+	}
+	box := Box{Name: "devbox", Namespace: "dev"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := box.ephemeralRunning("web", []byte(tt.status))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ephemeralRunning() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ephemeralRunning() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package devbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// DefaultDockerfile is the Dockerfile of a Build, relative to its context, if
+// none is configured.
+const DefaultDockerfile = "Dockerfile"
+
+// Build contains configuration to build the image of a Box from a Dockerfile.
+type Build struct {
+	// Context is the absolute path to the build context directory.
+	Context string `yaml:"context"`
+
+	// Dockerfile is the path to the Dockerfile, relative to the build context
+	// unless absolute.
+	Dockerfile string `yaml:"dockerfile"`
+
+	// Args are the build-time variables passed to the build.
+	Args map[string]string `yaml:"args"`
+}
+
+// NewBuild returns a validated Build of a context directory. Relative context
+// paths are made absolute so that the image can be built from any directory.
+func NewBuild(context string, dockerfile string, args map[string]string) (*Build, error) {
+	if dockerfile == "" {
+		dockerfile = DefaultDockerfile
+	}
+	context, err := homedir.Expand(context)
+	if err != nil {
+		return nil, err
+	}
+	context, err = filepath.Abs(context)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		args = nil
+	}
+	build := &Build{Context: context, Dockerfile: dockerfile, Args: args}
+	if err := build.Validate(); err != nil {
+		return nil, err
+	}
+	return build, nil
+}
+
+// Validate ensures a Build context directory and Dockerfile exist.
+func (build Build) Validate() error {
+	if !util.DirExists(build.Context) {
+		return fmt.Errorf("build context %s not found", build.Context)
+	}
+	if !util.FileExists(build.dockerfilePath()) {
+		return fmt.Errorf("dockerfile %s not found", build.dockerfilePath())
+	}
+	return nil
+}
+
+// dockerfilePath returns the absolute path to the Dockerfile of a Build.
+func (build Build) dockerfilePath() string {
+	dockerfile := build.Dockerfile
+	if dockerfile == "" {
+		dockerfile = DefaultDockerfile
+	}
+	if filepath.IsAbs(dockerfile) {
+		return dockerfile
+	}
+	return filepath.Join(build.Context, dockerfile)
+}
+
+// Hash returns a hash of the inputs of a Build, namely its Dockerfile, build
+// args and the files in its context, which changes whenever they do. Version
+// control directories are not considered part of the context.
+func (build Build) Hash() (string, error) {
+	hash := sha256.New()
+
+	for _, key := range sortedKeys(build.Args) {
+		fmt.Fprintf(hash, "arg %s=%s\n", key, build.Args[key])
+	}
+	if err := hashFile(hash, "dockerfile", build.dockerfilePath()); err != nil {
+		return "", err
+	}
+
+	// Files are walked in lexical order, so the hash is stable.
+	err := filepath.Walk(build.Context, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(build.Context, path)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			fmt.Fprintf(hash, "file %s %s\n", rel, info.Mode())
+			return nil
+		}
+		return hashFile(hash, fmt.Sprintf("file %s %s", rel, info.Mode()), path)
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashFile writes a header and the contents of a file to a hash.
+func hashFile(hash io.Writer, header string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fmt.Fprintf(hash, "%s\n", header)
+	_, err = io.Copy(hash, file)
+	return err
+}
+
+// args returns the docker build arguments of a Build tagging its image.
+func (build Build) args(image string) []string {
+	args := []string{"build", "--tag", image, "--file", build.dockerfilePath()}
+	for _, key := range sortedKeys(build.Args) {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", key, build.Args[key]))
+	}
+	return append(args, build.Context)
+}
+
+// BuildImage builds and tags the image of a Box with a Build, unless its
+// inputs are unchanged since it was last built and the image exists, or force
+// is true. Images of Kubernetes devboxes are pushed once built, so that they
+// can be pulled by the cluster. The returned Box is the updated Box to save in
+// state, recording the hash of the build inputs.
+func (box Box) BuildImage(force bool) (Box, error) {
+	if box.Build == nil {
+		return box, errors.New("devbox has no build configuration")
+	}
+	if err := box.Build.Validate(); err != nil {
+		return box, err
+	}
+	hash, err := box.Build.Hash()
+	if err != nil {
+		return box, fmt.Errorf("cannot hash build inputs: %v", err)
+	}
+	if !force && hash == box.Runtime.BuildHash && box.imageExists() {
+		if config.Verbose {
+			fmt.Printf("msg: image %s of devbox %s is up to date\n", box.Image, box.Name)
+		}
+		return box, nil
+	}
+
+	if config.Verbose {
+		fmt.Printf("msg: building image %s of devbox %s from %s\n", box.Image, box.Name, box.Build.Context)
+	}
+	if err := util.ExecCommand("docker", box.Build.args(box.Image)...); err != nil {
+		return box, err
+	}
+	if box.Namespace != "" {
+		if err := util.ExecCommand("docker", "push", box.Image); err != nil {
+			return box, err
+		}
+	}
+	box.Runtime.BuildHash = hash
+	return box, nil
+}
+
+// imageExists tests if the image of a Box exists locally.
+func (box Box) imageExists() bool {
+	// Output of the inspect command is discarded, as it is expected to fail
+	// until the image is first built.
+	return exec.Command("docker", "image", "inspect", box.Image).Run() == nil
+}
@@ -0,0 +1,90 @@
+package devbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuild_Hash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devbox-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func(build Build) string {
+		got, err := build.Hash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	write("Dockerfile", "FROM ubuntu\n")
+	write("scripts/setup.sh", "echo setup\n")
+
+	build := Build{Context: dir}
+	base := hash(build)
+	if got := hash(build); got != base {
+		t.Errorf("Hash() not stable, got = %v, want %v", got, base)
+	}
+
+	write(".git/HEAD", "ref: refs/heads/main\n")
+	if got := hash(build); got != base {
+		t.Errorf("Hash() changed by version control files")
+	}
+
+	if got := hash(Build{Context: dir, Args: map[string]string{"GO_VERSION": "1.16"}}); got == base {
+		t.Errorf("Hash() unchanged by build args")
+	}
+
+	write("scripts/setup.sh", "echo setup again\n")
+	if got := hash(build); got == base {
+		t.Errorf("Hash() unchanged by context files")
+	}
+}
+
+func TestBuild_args(t *testing.T) {
+	tests := []struct {
+		name  string
+		build Build
+		want  []string
+	}{
+		{
+			name:  "test default dockerfile",
+			build: Build{Context: "/src"},
+			want:  []string{"build", "--tag", "devbox:latest", "--file", "/src/Dockerfile", "/src"},
+		},
+		{
+			name:  "test relative dockerfile with args",
+			build: Build{Context: "/src", Dockerfile: "devbox/Dockerfile", Args: map[string]string{"USER": "me", "GO_VERSION": "1.16"}},
+			want: []string{
+				"build", "--tag", "devbox:latest", "--file", "/src/devbox/Dockerfile",
+				"--build-arg", "GO_VERSION=1.16", "--build-arg", "USER=me", "/src",
+			},
+		},
+		{
+			name:  "test absolute dockerfile",
+			build: Build{Context: "/src", Dockerfile: "/etc/devbox/Dockerfile"},
+			want:  []string{"build", "--tag", "devbox:latest", "--file", "/etc/devbox/Dockerfile", "/src"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.build.args("devbox:latest"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package devbox
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// composeFileNames contains the names of docker compose files, in order of
+// precedence.
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yml", "docker-compose.yaml"}
+
+// invalidProjectChars matches characters docker compose removes from project
+// names.
+var invalidProjectChars = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// DiscoverComposeProject returns the name of the docker compose project of a
+// directory, as determined by docker compose. The compose file is looked up
+// in the directory and its parents, and the project name is taken from the
+// COMPOSE_PROJECT_NAME environment variable or .env file variable, the name
+// of the compose file, or the name of its directory, in that order.
+func DiscoverComposeProject(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	file, err := findComposeFile(dir)
+	if err != nil {
+		return "", err
+	}
+	projectDir := filepath.Dir(file)
+
+	name := os.Getenv("COMPOSE_PROJECT_NAME")
+	if name == "" {
+		name = readDotEnv(filepath.Join(projectDir, ".env"))["COMPOSE_PROJECT_NAME"]
+	}
+	if name == "" {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		var compose struct {
+			Name string `json:"name"`
+		}
+		if err := yaml.Unmarshal(buf, &compose); err != nil {
+			return "", fmt.Errorf("cannot parse %s: %v", file, err)
+		}
+		name = compose.Name
+	}
+	if name == "" {
+		name = filepath.Base(projectDir)
+	}
+	name = invalidProjectChars.ReplaceAllString(strings.ToLower(name), "")
+	if name == "" {
+		return "", fmt.Errorf("cannot determine compose project name of %s", file)
+	}
+	return name, nil
+}
+
+// findComposeFile returns the path to the docker compose file of a directory
+// or its nearest parent.
+func findComposeFile(dir string) (string, error) {
+	for current := dir; ; current = filepath.Dir(current) {
+		for _, name := range composeFileNames {
+			file := filepath.Join(current, name)
+			if util.FileExists(file) {
+				return file, nil
+			}
+		}
+		if filepath.Dir(current) == current {
+			return "", fmt.Errorf("no docker compose file found in %s or its parents", dir)
+		}
+	}
+}
+
+// readDotEnv returns the variables of a .env file, or none if it cannot be
+// read.
+func readDotEnv(path string) map[string]string {
+	vars := make(map[string]string)
+	file, err := os.Open(path)
+	if err != nil {
+		return vars
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		vars[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+	}
+	return vars
+}
+
+// networks returns the docker networks a Docker Box joins when started, which
+// are its configured network followed by those of its compose project. The
+// compose project must be up for its networks to exist.
+func (box Box) networks() ([]string, error) {
+	var networks []string
+	if box.Network != "" {
+		networks = append(networks, box.Network)
+	}
+	if box.ComposeProject == "" {
+		return networks, nil
+	}
+	filter := fmt.Sprintf("label=com.docker.compose.project=%s", box.ComposeProject)
+	out, err := util.OutputCommand("docker", "network", "ls", "--filter", filter, "--format", "{{.Name}}")
+	if err != nil && !config.DryRun {
+		return nil, fmt.Errorf("cannot list networks of compose project %s: %v", box.ComposeProject, err)
+	}
+	projectNetworks := strings.Fields(string(out))
+	if len(projectNetworks) == 0 {
+		if !config.DryRun {
+			return nil, fmt.Errorf("compose project %s has no networks, start it with docker compose up", box.ComposeProject)
+		}
+		// The default network of the project is assumed when previewing.
+		projectNetworks = []string{fmt.Sprintf("%s_default", box.ComposeProject)}
+	}
+	for _, network := range projectNetworks {
+		if !util.ContainsString(networks, network) {
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
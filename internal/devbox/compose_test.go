@@ -0,0 +1,95 @@
+package devbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoverComposeProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devbox-compose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"My.App/docker-compose.yml":     "services:\n  db:\n    image: postgres\n",
+		"My.App/src/main.go":            "package main\n",
+		"named/compose.yaml":            "name: shop\nservices:\n  db:\n    image: postgres\n",
+		"dotenv/compose.yml":            "name: ignored\nservices: {}\n",
+		"dotenv/.env":                   "# Project\nCOMPOSE_PROJECT_NAME=\"billing\"\n",
+		"none/README.md":                "no compose here\n",
+		"precedence/compose.yaml":       "name: wins\n",
+		"precedence/docker-compose.yml": "name: loses\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "test project named after directory",
+			dir:  "My.App",
+			want: "myapp",
+		},
+		{
+			name: "test compose file found in parent",
+			dir:  "My.App/src",
+			want: "myapp",
+		},
+		{
+			name: "test project named in compose file",
+			dir:  "named",
+			want: "shop",
+		},
+		{
+			name: "test project named in .env file",
+			dir:  "dotenv",
+			want: "billing",
+		},
+		{
+			name: "test project named in environment",
+			dir:  "named",
+			env:  "Override",
+			want: "override",
+		},
+		{
+			name: "test compose file precedence",
+			dir:  "precedence",
+			want: "wins",
+		},
+		{
+			name:    "test crappy directory without compose file",
+			dir:     "none",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("COMPOSE_PROJECT_NAME", tt.env)
+			defer os.Unsetenv("COMPOSE_PROJECT_NAME")
+			got, err := DiscoverComposeProject(filepath.Join(dir, tt.dir))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DiscoverComposeProject() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("DiscoverComposeProject() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package devbox
+
+import (
+	"fmt"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/credentials"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// credentialsFile is the path of the file in a Box exporting the environment
+// variables of its injected credentials, which its shells source. It is kept
+// in /dev/shm, a tmpfs in Docker containers and Kubernetes pods, so that
+// credentials are never written to the container filesystem, from which they
+// could be committed to a snapshot image.
+const credentialsFile = "/dev/shm/devbox-credentials.env"
+
+// InjectCredentials resolves the local cloud credentials of a Box and writes
+// them to its credentials file, readable by the devbox user only. Credentials
+// are short-lived, so they are injected again whenever a shell is opened.
+func (box Box) InjectCredentials() error {
+	if len(box.Credentials) == 0 {
+		return nil
+	}
+	if config.Verbose {
+		fmt.Printf("msg: injecting credentials in devbox %s\n", box.Name)
+	}
+	// Credentials are not resolved when previewing, as the provider CLIs may
+	// prompt to log in.
+	var vars map[string]string
+	if !config.DryRun {
+		var err error
+		if vars, err = credentials.Resolve(box.Credentials); err != nil {
+			return err
+		}
+	}
+	name, args := box.execStreamArgs("sh", "-c", fmt.Sprintf("umask 077 && cat > %s", credentialsFile))
+	return util.ExecCommandWithInput(credentials.EnvFile(vars), name, args...)
+}
+
+// credentialsShellCommand returns the command opening a shell in a Box with
+// the environment variables of its credentials file.
+func credentialsShellCommand(shellPath string) []string {
+	script := fmt.Sprintf(`set -a && . %s && set +a && exec "$0"`, credentialsFile)
+	return []string{"sh", "-c", script, shellPath}
+}
@@ -0,0 +1,15 @@
+package devbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_credentialsShellCommand(t *testing.T) {
+	// Credentials are sourced from a tmpfs, never from the container
+	// filesystem.
+	want := []string{"sh", "-c", `set -a && . /dev/shm/devbox-credentials.env && set +a && exec "$0"`, "/bin/zsh"}
+	if got := credentialsShellCommand("/bin/zsh"); !reflect.DeepEqual(got, want) {
+		t.Errorf("credentialsShellCommand() got = %v, want %v", got, want)
+	}
+}
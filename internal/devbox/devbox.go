@@ -2,11 +2,16 @@
 package devbox
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/mitchellh/go-homedir"
+
+	"github.com/mojochao/devbox/internal/audit"
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/credentials"
 	"github.com/mojochao/devbox/internal/util"
 )
 
@@ -32,6 +37,100 @@ type Config struct {
 
 	// Description of devbox.
 	Description string
+
+	// Resources contains compute resource requests and limits of devbox.
+	Resources Resources
+
+	// NodeSelector constrains the nodes a devbox pod may be scheduled on.
+	NodeSelector map[string]string
+
+	// Tolerations allow a devbox pod to be scheduled on tainted nodes.
+	Tolerations []Toleration
+
+	// Affinity contains the Kubernetes affinity rules of a devbox pod.
+	Affinity map[string]interface{}
+
+	// ServiceAccount is the service account of a devbox pod.
+	ServiceAccount string
+
+	// PodPatch is a patch applied to the generated devbox pod manifest.
+	PodPatch *PodPatch
+
+	// Readiness determines when a started devbox is ready.
+	Readiness Readiness
+
+	// KeepAlive overrides the devbox image entrypoint, if set.
+	KeepAlive *KeepAlive
+
+	// Build builds the devbox image from a Dockerfile before it is started,
+	// if set.
+	Build *Build
+
+	// Env contains the environment variables of a devbox.
+	Env map[string]string
+
+	// Mounts contains the bind mounts, volumes and tmpfs mounts of a Docker
+	// devbox.
+	Mounts []Mount
+
+	// Ports contains the devbox ports published on localhost.
+	Ports []int
+
+	// SetupCommands contains commands in exec form run when a devbox is setup.
+	SetupCommands [][]string
+
+	// Network is the docker network a Docker devbox is run on.
+	Network string
+
+	// ComposeProject is the docker compose project whose networks a Docker
+	// devbox joins.
+	ComposeProject string
+
+	// ForwardSSHAgent forwards the local SSH agent to a devbox.
+	ForwardSSHAgent bool
+
+	// ForwardGPGAgent forwards the local GPG agent to a devbox.
+	ForwardGPGAgent bool
+
+	// ForwardGitCredentials forwards git credential requests of a devbox to
+	// the local git credential helpers.
+	ForwardGitCredentials bool
+
+	// SecretPolicy determines how files containing secrets are handled when a
+	// devbox is setup, defaulting to block for Kubernetes devboxes and warn
+	// for Docker devboxes.
+	SecretPolicy SecretPolicy
+
+	// Credentials contains the sources of the local cloud credentials
+	// injected in a devbox.
+	Credentials []credentials.Source
+
+	// SecretMounts contains the Kubernetes Secrets mounted in a Kubernetes
+	// devbox.
+	SecretMounts []ObjectMount
+
+	// ConfigMapMounts contains the Kubernetes ConfigMaps mounted in a
+	// Kubernetes devbox.
+	ConfigMapMounts []ObjectMount
+
+	// ImagePullSecrets contains the names of the Kubernetes Secrets used to
+	// pull the image of a Kubernetes devbox.
+	ImagePullSecrets []string
+
+	// Digest pins the image of a devbox to a digest, if set.
+	Digest string
+
+	// Verification verifies the signature of the image of a devbox before it
+	// is started, if set.
+	Verification *Verification
+
+	// SecurityProfile is the Pod Security Standard a Kubernetes devbox
+	// complies with, if set.
+	SecurityProfile SecurityProfile
+
+	// RunAs contains the user and group IDs a Kubernetes devbox runs as, if
+	// set.
+	RunAs *RunAs
 }
 
 // DefaultConfig is a Config containing default configuration values.
@@ -43,6 +142,7 @@ var DefaultConfig = Config{
 	Namespace:   "",
 	Kubeconfig:  "",
 	Description: "",
+	Resources:   Resources{Ulimits: append([]string{}, DefaultUlimits...)},
 }
 
 // Box contains information on a devbox.
@@ -70,6 +170,114 @@ type Box struct {
 
 	// Manifest of devbox.
 	Manifest Manifest `yaml:"defaultManifest"`
+
+	// Resources contains compute resource requests and limits of devbox.
+	Resources Resources `yaml:"resources"`
+
+	// NodeSelector constrains the nodes a devbox pod may be scheduled on.
+	NodeSelector map[string]string `yaml:"nodeSelector"`
+
+	// Tolerations allow a devbox pod to be scheduled on tainted nodes.
+	Tolerations []Toleration `yaml:"tolerations"`
+
+	// Affinity contains the Kubernetes affinity rules of a devbox pod.
+	Affinity map[string]interface{} `yaml:"affinity"`
+
+	// ServiceAccount is the service account of a devbox pod.
+	ServiceAccount string `yaml:"serviceAccount"`
+
+	// PodPatch is a patch applied to the generated devbox pod manifest.
+	PodPatch *PodPatch `yaml:"podPatch"`
+
+	// Readiness determines when a started devbox is ready.
+	Readiness Readiness `yaml:"readiness"`
+
+	// KeepAlive overrides the devbox image entrypoint, if set.
+	KeepAlive *KeepAlive `yaml:"keepAlive"`
+
+	// Build builds the devbox image from a Dockerfile before it is started,
+	// if set.
+	Build *Build `yaml:"build"`
+
+	// Env contains the environment variables of a devbox.
+	Env map[string]string `yaml:"env"`
+
+	// Mounts contains the bind mounts, volumes and tmpfs mounts of a Docker
+	// devbox.
+	Mounts []Mount `yaml:"mounts"`
+
+	// Ports contains the devbox ports published on localhost.
+	Ports []int `yaml:"ports"`
+
+	// SetupCommands contains commands in exec form run when a devbox is setup.
+	SetupCommands [][]string `yaml:"setupCommands"`
+
+	// Network is the docker network a Docker devbox is run on.
+	Network string `yaml:"network"`
+
+	// ComposeProject is the docker compose project whose networks a Docker
+	// devbox joins.
+	ComposeProject string `yaml:"composeProject"`
+
+	// ForwardSSHAgent forwards the local SSH agent to a devbox.
+	ForwardSSHAgent bool `yaml:"forwardSSHAgent"`
+
+	// ForwardGPGAgent forwards the local GPG agent to a devbox.
+	ForwardGPGAgent bool `yaml:"forwardGPGAgent"`
+
+	// ForwardGitCredentials forwards git credential requests of a devbox to
+	// the local git credential helpers.
+	ForwardGitCredentials bool `yaml:"forwardGitCredentials"`
+
+	// SecretPolicy determines how files containing secrets are handled when a
+	// devbox is setup, defaulting to block for Kubernetes devboxes and warn
+	// for Docker devboxes.
+	SecretPolicy SecretPolicy `yaml:"secretPolicy"`
+
+	// Credentials contains the sources of the local cloud credentials
+	// injected in a devbox.
+	Credentials []credentials.Source `yaml:"credentials"`
+
+	// SecretMounts contains the Kubernetes Secrets mounted in a Kubernetes
+	// devbox.
+	SecretMounts []ObjectMount `yaml:"secretMounts"`
+
+	// ConfigMapMounts contains the Kubernetes ConfigMaps mounted in a
+	// Kubernetes devbox.
+	ConfigMapMounts []ObjectMount `yaml:"configMapMounts"`
+
+	// ImagePullSecrets contains the names of the Kubernetes Secrets used to
+	// pull the image of a Kubernetes devbox.
+	ImagePullSecrets []string `yaml:"imagePullSecrets"`
+
+	// Digest pins the image of a devbox to a digest, if set.
+	Digest string `yaml:"digest"`
+
+	// Verification verifies the signature of the image of a devbox before it
+	// is started, if set.
+	Verification *Verification `yaml:"verification"`
+
+	// SecurityProfile is the Pod Security Standard a Kubernetes devbox
+	// complies with, if set.
+	SecurityProfile SecurityProfile `yaml:"securityProfile"`
+
+	// RunAs contains the user and group IDs a Kubernetes devbox runs as, if
+	// set.
+	RunAs *RunAs `yaml:"runAs"`
+
+	// Attachment describes the existing workload the devbox is attached to,
+	// if any.
+	Attachment *Attachment `yaml:"attachment"`
+
+	// Runtime contains information recorded on the use of the devbox.
+	Runtime Runtime `yaml:"runtime"`
+
+	// pullAlways forces the devbox image to be pulled when started.
+	pullAlways bool
+
+	// verifiedDigest is the digest of the image verified when started, which
+	// is run rather than its tag.
+	verifiedDigest string
 }
 
 // New returns a fully constructed Box.
@@ -98,17 +306,165 @@ func New(cfg *Config) Box {
 		if cfg.Description == "" {
 			cfg.Description = DefaultConfig.Description
 		}
+		if len(cfg.Resources.Ulimits) == 0 && cfg.Namespace == "" {
+			cfg.Resources.Ulimits = append([]string{}, DefaultConfig.Resources.Ulimits...)
+		}
 	}
 	return Box{
-		Image:       cfg.Image,
-		User:        cfg.User,
-		Shell:       cfg.Shell,
-		Name:        cfg.Name,
-		Namespace:   cfg.Namespace,
-		Kubeconfig:  cfg.Kubeconfig,
-		Description: cfg.Description,
-		Manifest:    defaultManifest,
+		Image:                 cfg.Image,
+		User:                  cfg.User,
+		Shell:                 cfg.Shell,
+		Name:                  cfg.Name,
+		Namespace:             cfg.Namespace,
+		Kubeconfig:            cfg.Kubeconfig,
+		Description:           cfg.Description,
+		Manifest:              defaultManifest,
+		Resources:             cfg.Resources,
+		NodeSelector:          cfg.NodeSelector,
+		Tolerations:           cfg.Tolerations,
+		Affinity:              cfg.Affinity,
+		ServiceAccount:        cfg.ServiceAccount,
+		PodPatch:              cfg.PodPatch,
+		Readiness:             cfg.Readiness,
+		KeepAlive:             cfg.KeepAlive,
+		Build:                 cfg.Build,
+		Env:                   cfg.Env,
+		Mounts:                cfg.Mounts,
+		Ports:                 cfg.Ports,
+		SetupCommands:         cfg.SetupCommands,
+		Network:               cfg.Network,
+		ComposeProject:        cfg.ComposeProject,
+		ForwardSSHAgent:       cfg.ForwardSSHAgent,
+		ForwardGPGAgent:       cfg.ForwardGPGAgent,
+		ForwardGitCredentials: cfg.ForwardGitCredentials,
+		SecretPolicy:          cfg.SecretPolicy,
+		Credentials:           cfg.Credentials,
+		SecretMounts:          cfg.SecretMounts,
+		ConfigMapMounts:       cfg.ConfigMapMounts,
+		ImagePullSecrets:      cfg.ImagePullSecrets,
+		Digest:                cfg.Digest,
+		Verification:          cfg.Verification,
+		SecurityProfile:       cfg.SecurityProfile,
+		RunAs:                 cfg.RunAs,
+	}
+}
+
+// Validate ensures a Box is correctly configured.
+func (box Box) Validate() error {
+	if err := box.Resources.Validate(); err != nil {
+		return err
 	}
+	if box.Namespace != "" && len(box.Resources.Ulimits) > 0 {
+		return errors.New("ulimits are only supported for Docker devboxes")
+	}
+	if box.Namespace == "" && box.hasScheduling() {
+		return errors.New("node selectors, tolerations, affinity and service accounts are only supported for Kubernetes devboxes")
+	}
+	for _, toleration := range box.Tolerations {
+		if err := toleration.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := box.Readiness.Validate(); err != nil {
+		return err
+	}
+	if box.KeepAlive != nil && len(box.KeepAlive.Command) == 0 {
+		return errors.New("keep-alive command cannot be empty")
+	}
+	if box.Build != nil {
+		if err := box.Build.Validate(); err != nil {
+			return err
+		}
+	}
+	if box.Namespace != "" && len(box.Mounts) > 0 {
+		return errors.New("mounts are only supported for Docker devboxes")
+	}
+	if box.Namespace != "" && (box.Network != "" || box.ComposeProject != "") {
+		return errors.New("networks and compose projects are only supported for Docker devboxes")
+	}
+	if box.Namespace == "" && (len(box.SecretMounts) > 0 || len(box.ConfigMapMounts) > 0) {
+		return errors.New("secret and config map mounts are only supported for Kubernetes devboxes")
+	}
+	if box.Build != nil && (box.Digest != "" || box.Verification != nil) {
+		return errors.New("images built by devbox cannot be pinned or verified")
+	}
+	if box.Digest != "" && !strings.HasPrefix(box.Digest, "sha256:") {
+		return fmt.Errorf("invalid image digest %q", box.Digest)
+	}
+	if box.Verification != nil {
+		if err := box.Verification.Validate(); err != nil {
+			return err
+		}
+	}
+	if box.Namespace == "" && len(box.ImagePullSecrets) > 0 {
+		return errors.New("image pull secrets are only supported for Kubernetes devboxes")
+	}
+	for _, name := range box.ImagePullSecrets {
+		if !objectNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid image pull secret name %q", name)
+		}
+	}
+	if box.Namespace == "" && (box.SecurityProfile != "" || box.RunAs != nil) {
+		return errors.New("security profiles and run as users are only supported for Kubernetes devboxes")
+	}
+	if box.SecurityProfile != "" && !util.ContainsString(SecurityProfiles, box.SecurityProfile) {
+		return fmt.Errorf("invalid security profile %q, want one of %s", box.SecurityProfile, strings.Join(SecurityProfiles, ", "))
+	}
+	if box.SecurityProfile == SecurityRestricted && (box.RunAs == nil || box.RunAs.User == 0) {
+		return errors.New("restricted security profile requires a non-root run as user")
+	}
+	var paths []string
+	for _, mount := range append(append([]ObjectMount{}, box.SecretMounts...), box.ConfigMapMounts...) {
+		if err := mount.Validate(); err != nil {
+			return err
+		}
+		if util.ContainsString(paths, mount.Path) {
+			return fmt.Errorf("duplicate mount path %s", mount.Path)
+		}
+		paths = append(paths, mount.Path)
+	}
+	for _, mount := range box.Mounts {
+		if err := mount.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, port := range box.Ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+	}
+	for _, command := range box.SetupCommands {
+		if len(command) == 0 {
+			return errors.New("setup commands cannot be empty")
+		}
+	}
+	if box.SecretPolicy != "" && !util.ContainsString(SecretPolicies, box.SecretPolicy) {
+		return fmt.Errorf("invalid secret policy %q, want one of %s", box.SecretPolicy, strings.Join(SecretPolicies, ", "))
+	}
+	var providers []string
+	for _, source := range box.Credentials {
+		if err := source.Validate(); err != nil {
+			return err
+		}
+		if util.ContainsString(providers, source.Provider) {
+			return fmt.Errorf("duplicate %s credentials", source.Provider)
+		}
+		providers = append(providers, source.Provider)
+	}
+	if box.PodPatch != nil {
+		if box.Namespace == "" {
+			return errors.New("pod patches are only supported for Kubernetes devboxes")
+		}
+		if err := box.PodPatch.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasScheduling tests if any Kubernetes scheduling controls are set.
+func (box Box) hasScheduling() bool {
+	return len(box.NodeSelector) > 0 || len(box.Tolerations) > 0 || len(box.Affinity) > 0 || box.ServiceAccount != ""
 }
 
 // HomeDir returns the home directory of the devbox user.
@@ -116,22 +472,160 @@ func (box Box) HomeDir() string {
 	return fmt.Sprintf("/home/%s", box.User)
 }
 
+// auditBox returns the audit log identity of a Box with an ID. The kubeconfig
+// is omitted if encrypted, as it is sensitive and the audit log is not.
+func (box Box) auditBox(id BoxID, encrypted bool) audit.Box {
+	if box.Namespace == "" {
+		return audit.Box{ID: id, Runtime: "docker", Name: box.Name}
+	}
+	auditBox := audit.Box{ID: id, Runtime: "kubernetes", Name: box.Name, Namespace: box.Namespace, Kubeconfig: box.Kubeconfig}
+	if encrypted {
+		auditBox.Kubeconfig = ""
+	}
+	return auditBox
+}
+
 // Start starts a Box.
 func (box Box) Start() error {
-	var command, message string
+	if box.Attachment != nil {
+		return fmt.Errorf("devbox %s is attached to %s and must be stopped first", box.Name, box.Attachment)
+	}
+	box, err := box.verifyImage()
+	if err != nil {
+		return err
+	}
 	if box.Namespace == "" {
-		command = fmt.Sprintf("docker run --detach --name %s --rm --ulimit nofile=90000:90000 %s", box.Name, box.Image)
-		message = fmt.Sprintf("starting devbox %s in docker", box.Name)
-	} else {
-		kubeconfig, _ := homedir.Expand(box.Kubeconfig)
-		command = fmt.Sprintf("kubectl --kubeconfig %s run --image %s %s -n %s", kubeconfig, box.Image, box.Name, box.Namespace)
-		message = fmt.Sprintf("starting devbox %s in cluster with %s kubeconfig", box.Name, box.Kubeconfig)
+		if config.Verbose {
+			fmt.Printf("msg: starting devbox %s in docker\n", box.Name)
+		}
+		networks, err := box.networks()
+		if err != nil {
+			return err
+		}
+		agentArgs, err := box.sshAgentDockerArgs()
+		if err != nil {
+			return err
+		}
+		// Containers are kept when they exit, so that the logs of crashed
+		// devboxes can be shown, and are removed before they are started
+		// again, which fails if they are still running.
+		exists, err := box.containerExists()
+		if err != nil {
+			return err
+		}
+		if exists {
+			if err := util.ExecCommand("docker", "rm", box.Name); err != nil {
+				return fmt.Errorf("cannot remove previous container of devbox %s: %v", box.Name, err)
+			}
+		}
+		args := []string{"run", "--detach", "--name", box.Name}
+		if len(networks) > 0 {
+			args = append(args, fmt.Sprintf("--network=%s", networks[0]))
+		}
+		args = append(args, agentArgs...)
+		args = append(args, box.Resources.dockerArgs()...)
+		args = append(args, box.dockerEnvArgs()...)
+		for _, mount := range box.Mounts {
+			args = append(args, mount.dockerArg())
+		}
+		for _, port := range box.Ports {
+			args = append(args, fmt.Sprintf("--publish=127.0.0.1:%d:%d", port, port))
+		}
+		if box.KeepAlive != nil {
+			args = append(args, box.KeepAlive.dockerArgs()...)
+		}
+		args = append(args, box.imageRef())
+		if box.KeepAlive != nil {
+			args = append(args, box.KeepAlive.dockerCommand()...)
+		}
+		if err := util.ExecCommand("docker", args...); err != nil {
+			return err
+		}
+		// Containers can only be run on a single network, so any others are
+		// connected once it is running.
+		for i := 1; i < len(networks); i++ {
+			network := networks[i]
+			if err := util.ExecCommand("docker", "network", "connect", network, box.Name); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	return execCommand(command, message)
+
+	if config.Verbose {
+		fmt.Printf("msg: starting devbox %s in cluster with %s kubeconfig\n", box.Name, box.Kubeconfig)
+	}
+	manifest, err := box.PodManifest()
+	if err != nil {
+		return err
+	}
+	kubeconfig, _ := homedir.Expand(box.Kubeconfig)
+	return util.ExecCommandWithInput(manifest, "kubectl", "--kubeconfig", kubeconfig, "create", "-n", box.Namespace, "-f", "-")
+}
+
+// Restart restarts a started Box. Docker containers are restarted in place,
+// preserving their filesystem and volumes, while Kubernetes pods, which cannot
+// be restarted, are deleted and created again.
+func (box Box) Restart() error {
+	if box.Attachment != nil {
+		return fmt.Errorf("devbox %s is attached to %s and cannot be restarted", box.Name, box.Attachment)
+	}
+	if box.Namespace == "" {
+		if config.Verbose {
+			fmt.Printf("msg: restarting devbox %s in docker\n", box.Name)
+		}
+		return util.ExecCommand("docker", "restart", box.Name)
+	}
+	if err := box.Stop(); err != nil {
+		return err
+	}
+	return box.Start()
+}
+
+// Recreate replaces a Box container or pod with a new one running the latest
+// version of its image.
+func (box Box) Recreate() error {
+	if box.Attachment != nil {
+		return fmt.Errorf("devbox %s is attached to %s and cannot be recreated", box.Name, box.Attachment)
+	}
+	if box.Namespace == "" {
+		if config.Verbose {
+			fmt.Printf("msg: recreating devbox %s in docker\n", box.Name)
+		}
+		// Images built by devbox are rebuilt rather than pulled.
+		if box.Build == nil {
+			if err := util.ExecCommand("docker", "pull", box.imageRef()); err != nil {
+				return err
+			}
+		}
+		// The container is removed rather than stopped, as it is kept once
+		// stopped, if it exists at all.
+		exists, err := box.containerExists()
+		if err != nil {
+			return err
+		}
+		if exists {
+			if err := util.ExecCommand("docker", "rm", "--force", box.Name); err != nil {
+				return err
+			}
+		}
+		return box.Start()
+	}
+	if err := box.Stop(); err != nil {
+		return err
+	}
+	box.pullAlways = true
+	return box.Start()
+}
+
+// SetupOptions contains options of the setup of a Box.
+type SetupOptions struct {
+	// SecretPolicy overrides the SecretPolicy of the Box, if set.
+	SecretPolicy SecretPolicy
 }
 
 // Setup sets up a Box.
-func (box Box) Setup(manifestType string) error {
+func (box Box) Setup(manifestType string, opts SetupOptions) error {
 	items := defaultManifest[manifestType]
 	for _, item := range items {
 		if item.Path == "" {
@@ -143,22 +637,17 @@ func (box Box) Setup(manifestType string) error {
 		if !strings.HasSuffix(item.Path, "/") && !util.FileExists(item.Path) {
 			continue
 		}
-		if err := box.copyPath(item.Path); err != nil {
+		if err := box.copyItem(item, opts); err != nil {
 			return err
 		}
 
-		namespace := ""
-		if box.Namespace != "" {
-			namespace = fmt.Sprintf("--namespace %s", box.Namespace)
-		}
-
 		for _, command := range item.Commands {
 			if command == breakCommand {
 				return nil
 			}
 
-			command = fmt.Sprintf("exec %s %s -- %s", namespace, box.Name, command)
-			if err := box.execCommand(strings.Split(command, " ")); err != nil {
+			args := box.execSubcommand(false, strings.Split(command, " ")...)
+			if err := box.execCommand(args); err != nil {
 				return err
 			}
 		}
@@ -166,17 +655,53 @@ func (box Box) Setup(manifestType string) error {
 	return nil
 }
 
+// RunSetupCommands runs the setup commands of a Box in it.
+func (box Box) RunSetupCommands() error {
+	for _, command := range box.SetupCommands {
+		if err := box.execCommand(box.execSubcommand(false, command...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containerExists tests if the container of a Docker Box exists, whether it
+// is running or has exited.
+func (box Box) containerExists() (bool, error) {
+	out, err := util.OutputCommand("docker", "ps", "--all", "--quiet", "--filter", fmt.Sprintf("name=^/%s$", box.Name))
+	if err != nil {
+		return false, fmt.Errorf("cannot find container of devbox %s: %v", box.Name, err)
+	}
+	return len(strings.TrimSpace(string(out))) > 0, nil
+}
+
+// dockerEnvArgs returns the docker run --env flags of a Box environment,
+// sorted by name.
+func (box Box) dockerEnvArgs() []string {
+	var args []string
+	for _, name := range sortedKeys(box.Env) {
+		args = append(args, fmt.Sprintf("--env=%s=%s", name, box.Env[name]))
+	}
+	return args
+}
+
 // Stop stops a Box.
 func (box Box) Stop() error {
-	var command, message string
+	if box.Attachment != nil {
+		return box.detach()
+	}
 	if box.Namespace == "" {
-		command = fmt.Sprintf("docker stop %s", box.Name)
-		message = fmt.Sprintf("stopping devbox %s in docker", box.Name)
-	} else {
-		kubeconfig, _ := homedir.Expand(box.Kubeconfig)
-		command = fmt.Sprintf("kubectl --kubeconfig %s delete pod %s -n %s", kubeconfig, box.Name, box.Namespace)
-		message = fmt.Sprintf("stopping devbox %s in %s namespace in cluster with %s kubeconfig", box.Name, box.Namespace, box.Kubeconfig)
+		// Containers are kept when they exit, so they are removed once
+		// stopped.
+		message := fmt.Sprintf("stopping devbox %s in docker", box.Name)
+		if err := execCommand(fmt.Sprintf("docker stop %s", box.Name), message); err != nil {
+			return err
+		}
+		return util.ExecCommand("docker", "rm", box.Name)
 	}
+	kubeconfig, _ := homedir.Expand(box.Kubeconfig)
+	command := fmt.Sprintf("kubectl --kubeconfig %s delete pod %s -n %s", kubeconfig, box.Name, box.Namespace)
+	message := fmt.Sprintf("stopping devbox %s in %s namespace in cluster with %s kubeconfig", box.Name, box.Namespace, box.Kubeconfig)
 	return execCommand(command, message)
 }
 
@@ -185,46 +710,108 @@ func (box Box) OpenShell(shellPath string) error {
 	if shellPath == "" {
 		shellPath = box.Shell
 	}
-	var command, message string
-	if box.Namespace == "" {
-		command = fmt.Sprintf("docker exec -it %s %s", box.Name, shellPath)
-		message = fmt.Sprintf("opening %s shell in devbox %s in docker", shellPath, box.Name)
-	} else {
-		kubeconfig, _ := homedir.Expand(box.Kubeconfig)
-		command = fmt.Sprintf("kubectl --kubeconfig %s exec -it %s -n %s -- %s", kubeconfig, box.Name, box.Namespace, shellPath)
-		message = fmt.Sprintf("opening %s shell in devbox %s in %s namespace in cluster with %s kubeconfig", box.Shell, box.Name, box.Namespace, box.Kubeconfig)
+	if config.Verbose {
+		if box.Namespace == "" {
+			fmt.Printf("msg: opening %s shell in devbox %s in docker\n", shellPath, box.Name)
+		} else {
+			fmt.Printf("msg: opening %s shell in devbox %s in %s namespace in cluster with %s kubeconfig\n", shellPath, box.Name, box.Namespace, box.Kubeconfig)
+		}
 	}
-	return execCommand(command, message)
+	command := []string{shellPath}
+	if len(box.Credentials) > 0 {
+		if err := box.InjectCredentials(); err != nil {
+			return err
+		}
+		command = credentialsShellCommand(shellPath)
+	}
+	env, stop, err := box.startForwarding()
+	if err != nil {
+		return err
+	}
+	defer stop()
+	if len(env) > 0 {
+		command = append(append([]string{"env"}, env...), command...)
+	}
+	return box.execCommand(box.execSubcommand(true, command...))
 }
 
 // CopyFile copies a file to a Box.
 func (box Box) CopyFile(src string, dst string) error {
-	var command, message string
-	if box.Namespace == "" {
-		command = fmt.Sprintf("docker cp %s %s:%s", src, dst, box.Name)
-		message = fmt.Sprintf("copying %s to %s in devbox %s in docker", src, dst, box.Name)
-	} else {
-		kubeconfig, _ := homedir.Expand(box.Kubeconfig)
-		command = fmt.Sprintf("kubectl --kubeconfig %s cp  %s %s:%s -n %s", kubeconfig, src, box.Name, dst, box.Namespace)
-		message = fmt.Sprintf("copying %s to %s in devbox %s in %s namespace in cluster with %s kubeconfig", src, dst, box.Name, box.Namespace, box.Kubeconfig)
+	if config.Verbose {
+		if box.Namespace == "" {
+			fmt.Printf("msg: copying %s to %s in devbox %s in docker\n", src, dst, box.Name)
+		} else {
+			fmt.Printf("msg: copying %s to %s in devbox %s in %s namespace in cluster with %s kubeconfig\n", src, dst, box.Name, box.Namespace, box.Kubeconfig)
+		}
 	}
-	return execCommand(command, message)
+	if err := box.execCommand(box.copySubcommand(src, dst)); err != nil {
+		return err
+	}
+	audit.RecordFile(src)
+	return nil
+}
+
+// copyItem copies the path of a ManifestItem to a Box, omitting files
+// containing secrets blocked by the secret policy of opts.
+func (box Box) copyItem(item ManifestItem, opts SetupOptions) error {
+	policy := box.setupSecretPolicy(opts)
+	if policy == SecretPolicyAllow {
+		return box.copyPath(item.Path)
+	}
+	src, err := resolvePath(item.Path)
+	if err != nil {
+		return err
+	}
+	blocked, err := screenSecrets(src, policy)
+	if err != nil {
+		return err
+	}
+	if len(blocked) == 0 {
+		return box.copyPath(item.Path)
+	}
+	if !strings.HasSuffix(item.Path, "/") {
+		return nil
+	}
+	staged, tmp, err := stageDir(src, func(path string) (bool, error) {
+		return blocked[path], nil
+	})
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	dst := strings.Replace(item.Path, "~", box.HomeDir(), 1)
+	if err := box.execCommand(box.copySubcommand(staged+"/", dst)); err != nil {
+		return err
+	}
+	audit.RecordFile(src)
+	return nil
 }
 
 func (box Box) copyPath(path string) error {
+	src, err := resolvePath(path)
+	if err != nil {
+		return err
+	}
+	dst := strings.Replace(path, "~", box.HomeDir(), 1)
+	if err := box.execCommand(box.copySubcommand(src, dst)); err != nil {
+		return err
+	}
+	audit.RecordFile(src)
+	return nil
+}
+
+// resolvePath expands a local path and resolves it if it is a link.
+func resolvePath(path string) (string, error) {
 	src, _ := homedir.Expand(path)
 	src, err := os.Readlink(src)
 	if err != nil {
 		_, ok := err.(*os.PathError)
 		if !ok {
-			return err
+			return "", err
 		}
 		src, _ = homedir.Expand(path)
 	}
-
-	dst := fmt.Sprintf("%s:%s", box.Name, strings.Replace(path, "~", box.HomeDir(), 1))
-	args := []string{"cp", src, dst}
-	return box.execCommand(args)
+	return src, nil
 }
 
 func (box Box) execCommand(args []string) error {
@@ -238,11 +825,81 @@ func (box Box) execCommand(args []string) error {
 	if box.Namespace == "" {
 		return util.ExecCommand("docker", args...)
 	}
+	return util.ExecCommand("kubectl", box.kubectlArgs(args...)...)
+}
+
+// kubectlArgs returns args prefixed with the kubeconfig and namespace
+// arguments of a Kubernetes Box.
+func (box Box) kubectlArgs(args ...string) []string {
 	kubeconfig, _ := homedir.Expand(box.Kubeconfig)
 	kubeArgs := []string{
 		fmt.Sprintf("--kubeconfig=%s", kubeconfig),
 		fmt.Sprintf("--namespace=%s", box.Namespace),
 	}
-	kubeArgs = append(kubeArgs, args...)
-	return util.ExecCommand("kubectl", kubeArgs...)
+	return append(kubeArgs, args...)
+}
+
+// podName returns the name of the pod hosting a Kubernetes Box, which is the
+// attached pod if any.
+func (box Box) podName() string {
+	if box.Attachment != nil && box.Attachment.Pod != "" {
+		return box.Attachment.Pod
+	}
+	return box.Name
+}
+
+// containerArgs returns the kubectl arguments selecting the devbox container
+// in a pod hosting other containers.
+func (box Box) containerArgs() []string {
+	if box.Attachment != nil && box.Attachment.Pod != "" {
+		return []string{"-c", box.Name}
+	}
+	return nil
+}
+
+// execSubcommand returns the docker or kubectl exec subcommand and args
+// executing a command in a Box.
+func (box Box) execSubcommand(interactive bool, command ...string) []string {
+	args := []string{"exec"}
+	if interactive {
+		args = append(args, "-it")
+	}
+	if box.Namespace == "" {
+		args = append(args, box.Name)
+		return append(args, command...)
+	}
+	args = append(args, box.podName())
+	args = append(args, box.containerArgs()...)
+	args = append(args, "--")
+	return append(args, command...)
+}
+
+// copySubcommand returns the docker or kubectl cp subcommand and args copying
+// a local src path to a dst path in a Box.
+func (box Box) copySubcommand(src string, dst string) []string {
+	if box.Namespace == "" {
+		return []string{"cp", src, fmt.Sprintf("%s:%s", box.Name, dst)}
+	}
+	args := []string{"cp", src, fmt.Sprintf("%s:%s", box.podName(), dst)}
+	return append(args, box.containerArgs()...)
+}
+
+// execArgs returns the name and args of a command executing a non-interactive
+// command in a Box.
+func (box Box) execArgs(command ...string) (string, []string) {
+	args := box.execSubcommand(false, command...)
+	if box.Namespace == "" {
+		return "docker", args
+	}
+	return "kubectl", box.kubectlArgs(args...)
+}
+
+// execStreamArgs returns the name and args of a command executing a
+// non-interactive command in a Box with stdin attached.
+func (box Box) execStreamArgs(command ...string) (string, []string) {
+	args := append([]string{"exec", "-i"}, box.execSubcommand(false, command...)[1:]...)
+	if box.Namespace == "" {
+		return "docker", args
+	}
+	return "kubectl", box.kubectlArgs(args...)
 }
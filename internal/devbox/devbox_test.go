@@ -0,0 +1,100 @@
+package devbox
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeDocker is a fake docker command keeping a devbox container while a
+// container file exists in its directory.
+const fakeDocker = `dir=$(dirname "$0")
+case "$1" in
+ps) [ -f "$dir/container" ] && echo 0123456789ab ;;
+rm) [ -f "$dir/container" ] || { echo "Error: No such container: $2" >&2; exit 1; }; rm "$dir/container" ;;
+esac
+exit 0
+`
+
+func TestBox_Restart(t *testing.T) {
+	tests := []struct {
+		name    string
+		box     Box
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "test happy path docker restarted in place",
+			box:  Box{Name: "devbox", Image: "ubuntu"},
+			want: []string{"restart devbox"},
+		},
+		{
+			name:    "test crappy attached devbox",
+			box:     Box{Name: "devbox", Image: "ubuntu", Attachment: &Attachment{Mode: AttachContainer, Container: "web"}},
+			want:    []string{""},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, commands := fakeCommand(t, "docker", fakeDocker)
+			if err := tt.box.Restart(); (err != nil) != tt.wantErr {
+				t.Errorf("Restart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := commands(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restart() commands = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_Recreate(t *testing.T) {
+	run := "run --detach --name devbox --ulimit=nofile=90000:90000 ubuntu"
+	tests := []struct {
+		name      string
+		box       Box
+		container bool
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name:      "test happy path existing container removed",
+			box:       Box{Name: "devbox", Image: "ubuntu"},
+			container: true,
+			want:      []string{"pull ubuntu", "ps --all --quiet --filter name=^/devbox$", "rm --force devbox", "ps --all --quiet --filter name=^/devbox$", run},
+		},
+		{
+			name: "test happy path no such container",
+			box:  Box{Name: "devbox", Image: "ubuntu"},
+			want: []string{"pull ubuntu", "ps --all --quiet --filter name=^/devbox$", "ps --all --quiet --filter name=^/devbox$", run},
+		},
+		{
+			name: "test happy path built image not pulled",
+			box:  Box{Name: "devbox", Image: "ubuntu", Build: &Build{}},
+			want: []string{"ps --all --quiet --filter name=^/devbox$", "ps --all --quiet --filter name=^/devbox$", run},
+		},
+		{
+			name:    "test crappy attached devbox",
+			box:     Box{Name: "devbox", Image: "ubuntu", Attachment: &Attachment{Mode: AttachContainer, Container: "web"}},
+			want:    []string{""},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, commands := fakeCommand(t, "docker", fakeDocker)
+			if tt.container {
+				if err := os.WriteFile(filepath.Join(dir, "container"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.box.Recreate(); (err != nil) != tt.wantErr {
+				t.Errorf("Recreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := commands(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Recreate() commands = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
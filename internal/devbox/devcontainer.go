@@ -0,0 +1,394 @@
+package devbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// DevContainer contains the configuration of a Box translated from a
+// devcontainer.json file, as used by VS Code and other tools supporting the
+// development container specification.
+type DevContainer struct {
+	// Path is the absolute path to the devcontainer.json file.
+	Path string
+
+	// Name is the name of the development container.
+	Name string
+
+	// Image is the image of the development container, if not built.
+	Image string
+
+	// Build builds the image of the development container, if set.
+	Build *Build
+
+	// User is the user tools are run as in the development container.
+	User string
+
+	// Env contains the environment variables of the development container.
+	Env map[string]string
+
+	// Mounts contains the mounts of the development container.
+	Mounts []Mount
+
+	// Ports contains the ports of the development container forwarded to
+	// localhost.
+	Ports []int
+
+	// SetupCommands contains the commands run once the development container
+	// is created, in exec form.
+	SetupCommands [][]string
+
+	// Unsupported contains the keys of the devcontainer.json file that cannot
+	// be translated, sorted.
+	Unsupported []string
+}
+
+// devContainerFile is a devcontainer.json file. Properties with several
+// possible types are decoded as raw messages.
+type devContainerFile struct {
+	Name              string             `json:"name"`
+	Image             string             `json:"image"`
+	DockerFile        string             `json:"dockerFile"`
+	Context           string             `json:"context"`
+	Build             *devContainerBuild `json:"build"`
+	RemoteUser        string             `json:"remoteUser"`
+	ContainerUser     string             `json:"containerUser"`
+	ContainerEnv      map[string]string  `json:"containerEnv"`
+	Mounts            []json.RawMessage  `json:"mounts"`
+	ForwardPorts      []json.RawMessage  `json:"forwardPorts"`
+	PostCreateCommand json.RawMessage    `json:"postCreateCommand"`
+}
+
+// devContainerBuild is the build property of a devcontainer.json file.
+type devContainerBuild struct {
+	Dockerfile string            `json:"dockerfile"`
+	Context    string            `json:"context"`
+	Args       map[string]string `json:"args"`
+}
+
+// devContainerKeys contains the top-level devcontainer.json keys translated to
+// a Box.
+var devContainerKeys = []string{
+	"$schema", "name", "image", "dockerFile", "context", "build", "remoteUser", "containerUser",
+	"containerEnv", "mounts", "forwardPorts", "postCreateCommand",
+}
+
+// devContainerFileNames contains the paths of devcontainer.json files relative
+// to a project directory, in order of precedence.
+var devContainerFileNames = []string{
+	filepath.Join(".devcontainer", "devcontainer.json"),
+	".devcontainer.json",
+	"devcontainer.json",
+}
+
+// LoadDevContainer loads and translates a devcontainer.json file. The path may
+// be the file itself, or a project or .devcontainer directory containing it.
+func LoadDevContainer(path string) (DevContainer, error) {
+	path, err := findDevContainerFile(path)
+	if err != nil {
+		return DevContainer{}, err
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return DevContainer{}, err
+	}
+	buf = stripJSONC(buf)
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &keys); err != nil {
+		return DevContainer{}, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+	var file devContainerFile
+	if err := json.Unmarshal(buf, &file); err != nil {
+		return DevContainer{}, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+
+	dc := DevContainer{
+		Path:  path,
+		Name:  file.Name,
+		Image: file.Image,
+		User:  file.RemoteUser,
+	}
+	for key := range keys {
+		if !util.ContainsString(devContainerKeys, key) {
+			dc.Unsupported = append(dc.Unsupported, key)
+		}
+	}
+	if dc.User == "" {
+		dc.User = file.ContainerUser
+	}
+
+	// Values may reference the local workspace folder, which is the parent of
+	// a .devcontainer directory or the directory of the file otherwise.
+	dir := filepath.Dir(path)
+	workspace := dir
+	if filepath.Base(dir) == ".devcontainer" {
+		workspace = filepath.Dir(dir)
+	}
+	expand := func(s string) string {
+		return expandDevContainerVars(s, workspace)
+	}
+
+	if len(file.ContainerEnv) > 0 {
+		dc.Env = make(map[string]string)
+		for key, value := range file.ContainerEnv {
+			dc.Env[key] = expand(value)
+		}
+	}
+
+	// The Dockerfile and context are relative to the devcontainer.json file,
+	// unless they expand to absolute paths.
+	resolve := func(p string) string {
+		p = expand(p)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		return p
+	}
+	build := file.Build
+	if build == nil && file.DockerFile != "" {
+		build = &devContainerBuild{Dockerfile: file.DockerFile, Context: file.Context}
+	}
+	if build != nil && build.Dockerfile != "" {
+		context := build.Context
+		if context == "" {
+			context = "."
+		}
+		args := make(map[string]string)
+		for key, value := range build.Args {
+			args[key] = expand(value)
+		}
+		dc.Build, err = NewBuild(resolve(context), resolve(build.Dockerfile), args)
+		if err != nil {
+			return dc, err
+		}
+	}
+	if dc.Image == "" && dc.Build == nil {
+		return dc, fmt.Errorf("%s has neither an image nor a build", path)
+	}
+
+	for _, raw := range file.Mounts {
+		mount, err := parseDevContainerMount(raw, expand)
+		if err != nil {
+			return dc, err
+		}
+		dc.Mounts = append(dc.Mounts, mount)
+	}
+
+	for _, raw := range file.ForwardPorts {
+		var port int
+		if err := json.Unmarshal(raw, &port); err != nil {
+			// Ports of other hosts, such as docker compose services, are in
+			// host:port format and cannot be forwarded.
+			dc.Unsupported = append(dc.Unsupported, fmt.Sprintf("forwardPorts[%s]", strings.Trim(string(raw), `"`)))
+			continue
+		}
+		dc.Ports = append(dc.Ports, port)
+	}
+
+	dc.SetupCommands, err = parseDevContainerCommand(file.PostCreateCommand)
+	if err != nil {
+		return dc, fmt.Errorf("invalid postCreateCommand in %s: %v", path, err)
+	}
+
+	sort.Strings(dc.Unsupported)
+	return dc, nil
+}
+
+// findDevContainerFile returns the absolute path to a devcontainer.json file,
+// which may be in a directory path.
+func findDevContainerFile(path string) (string, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	for _, name := range devContainerFileNames {
+		file := filepath.Join(path, name)
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+	return "", fmt.Errorf("no devcontainer.json file found in %s", path)
+}
+
+// parseDevContainerMount parses a devcontainer.json mount, either in docker
+// run --mount flag format or as an object.
+func parseDevContainerMount(raw json.RawMessage, expand func(string) string) (Mount, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return ParseMount(expand(s))
+	}
+	var mount struct {
+		Type   string `json:"type"`
+		Source string `json:"source"`
+		Target string `json:"target"`
+	}
+	if err := json.Unmarshal(raw, &mount); err != nil {
+		return Mount{}, fmt.Errorf("invalid mount %s: %v", raw, err)
+	}
+	m := Mount{Type: mount.Type, Source: expand(mount.Source), Target: expand(mount.Target)}
+	return m, m.Validate()
+}
+
+// parseDevContainerCommand parses a devcontainer.json lifecycle command into
+// commands in exec form. Commands may be a string run by a shell, an array
+// run without one, or an object of named commands of either kind, which are
+// run in name order.
+func parseDevContainerCommand(raw json.RawMessage) ([][]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if s == "" {
+			return nil, nil
+		}
+		return [][]string{{"/bin/sh", "-c", s}}, nil
+	}
+	var args []string
+	if err := json.Unmarshal(raw, &args); err == nil {
+		if len(args) == 0 {
+			return nil, nil
+		}
+		return [][]string{args}, nil
+	}
+	var named map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &named); err != nil {
+		return nil, fmt.Errorf("want a string, array or object, got %s", raw)
+	}
+	var names []string
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var commands [][]string
+	for _, name := range names {
+		command := named[name]
+		if strings.HasPrefix(strings.TrimSpace(string(command)), "{") {
+			return nil, fmt.Errorf("command %s cannot be an object", name)
+		}
+		parsed, err := parseDevContainerCommand(command)
+		if err != nil {
+			return nil, fmt.Errorf("command %s: %v", name, err)
+		}
+		commands = append(commands, parsed...)
+	}
+	return commands, nil
+}
+
+// devContainerVar matches devcontainer.json variable references.
+var devContainerVar = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// expandDevContainerVars expands the local workspace folder and local
+// environment variable references of a devcontainer.json value. Other
+// references are left unchanged.
+func expandDevContainerVars(s string, workspace string) string {
+	return devContainerVar.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		switch {
+		case name == "localWorkspaceFolder":
+			return workspace
+		case name == "localWorkspaceFolderBasename":
+			return filepath.Base(workspace)
+		case strings.HasPrefix(name, "localEnv:"):
+			parts := strings.SplitN(name, ":", 3)
+			value := os.Getenv(parts[1])
+			if value == "" && len(parts) == 3 {
+				value = parts[2]
+			}
+			return value
+		}
+		return ref
+	})
+}
+
+// stripJSONC strips the comments and trailing commas allowed in JSON with
+// comments, as used by devcontainer.json files, returning plain JSON.
+func stripJSONC(buf []byte) []byte {
+	out := make([]byte, 0, len(buf))
+	inString := false
+	for i := 0; i < len(buf); i++ {
+		c := buf[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(buf) {
+				i++
+				out = append(out, buf[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(buf) && buf[i+1] == '/':
+			for i < len(buf) && buf[i] != '\n' {
+				i++
+			}
+			if i < len(buf) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(buf) && buf[i+1] == '*':
+			i += 2
+			for i+1 < len(buf) && !(buf[i] == '*' && buf[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ',':
+			// Trailing commas are dropped if only whitespace and comments
+			// precede the closing bracket or brace.
+			j := i + 1
+			for j < len(buf) {
+				if isJSONSpace(buf[j]) {
+					j++
+				} else if buf[j] == '/' && j+1 < len(buf) && buf[j+1] == '/' {
+					for j < len(buf) && buf[j] != '\n' {
+						j++
+					}
+				} else if buf[j] == '/' && j+1 < len(buf) && buf[j+1] == '*' {
+					j += 2
+					for j+1 < len(buf) && !(buf[j] == '*' && buf[j+1] == '/') {
+						j++
+					}
+					j += 2
+				} else {
+					break
+				}
+			}
+			if j >= len(buf) || (buf[j] != '}' && buf[j] != ']') {
+				out = append(out, c)
+			}
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// isJSONSpace tests if a byte is JSON whitespace.
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,215 @@
+package devbox
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_stripJSONC(t *testing.T) {
+	tests := []struct {
+		name  string
+		jsonc string
+		want  string
+	}{
+		{
+			name:  "test plain json",
+			jsonc: `{"image": "ubuntu"}`,
+			want:  `{"image": "ubuntu"}`,
+		},
+		{
+			name:  "test line and block comments",
+			jsonc: "{\n  // The image.\n  \"image\": /* base */ \"ubuntu\"\n}",
+			want:  "{\n  \n  \"image\":  \"ubuntu\"\n}",
+		},
+		{
+			name:  "test comment markers in strings",
+			jsonc: `{"url": "https://example.com/*", "cmd": "echo \"//\""}`,
+			want:  `{"url": "https://example.com/*", "cmd": "echo \"//\""}`,
+		},
+		{
+			name:  "test trailing commas",
+			jsonc: "{\"ports\": [3000, 8080,], \"image\": \"ubuntu\", // last\n}",
+			want:  "{\"ports\": [3000, 8080], \"image\": \"ubuntu\" \n}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stripJSONC([]byte(tt.jsonc))); got != tt.want {
+				t.Errorf("stripJSONC() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseDevContainerCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "test missing command",
+		},
+		{
+			name: "test string command",
+			raw:  `"npm install && npm run build"`,
+			want: [][]string{{"/bin/sh", "-c", "npm install && npm run build"}},
+		},
+		{
+			name: "test array command",
+			raw:  `["npm", "install"]`,
+			want: [][]string{{"npm", "install"}},
+		},
+		{
+			name: "test named commands",
+			raw:  `{"server": "npm install", "client": ["yarn", "install"]}`,
+			want: [][]string{{"yarn", "install"}, {"/bin/sh", "-c", "npm install"}},
+		},
+		{
+			name:    "test crappy command",
+			raw:     `42`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDevContainerCommand(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDevContainerCommand() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDevContainerCommand() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDevContainer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devbox-devcontainer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".devcontainer/devcontainer.json": `{
+  // Project development container.
+  "name": "project",
+  "build": {
+    "dockerfile": "Dockerfile",
+    "context": "..",
+    "args": {"VARIANT": "1.16"},
+  },
+  "remoteUser": "vscode",
+  "containerEnv": {"WORKSPACE": "${localWorkspaceFolder}"},
+  "mounts": [
+    "source=${localWorkspaceFolder},target=/workspace,type=bind,consistency=cached",
+    {"type": "volume", "source": "cache", "target": "/cache"}
+  ],
+  "forwardPorts": [3000, "db:5432"],
+  "postCreateCommand": "make deps",
+  "customizations": {"vscode": {"extensions": ["golang.go"]}},
+  "features": {},
+}`,
+		".devcontainer/Dockerfile": "FROM golang:1.16\n",
+		"absolute/.devcontainer/devcontainer.json": `{
+  "build": {
+    "dockerfile": "${localWorkspaceFolder}/build/Dockerfile",
+    "context": "${localWorkspaceFolder}",
+  },
+}`,
+		"absolute/build/Dockerfile": "FROM ubuntu\n",
+		"image/devcontainer.json":   `{"image": "ubuntu", "containerUser": "root"}`,
+		"empty/devcontainer.json":   `{"name": "nothing"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    DevContainer
+		wantErr bool
+	}{
+		{
+			name: "test happy path with build in project directory",
+			path: dir,
+			want: DevContainer{
+				Path: filepath.Join(dir, ".devcontainer", "devcontainer.json"),
+				Name: "project",
+				Build: &Build{
+					Context:    dir,
+					Dockerfile: filepath.Join(dir, ".devcontainer", "Dockerfile"),
+					Args:       map[string]string{"VARIANT": "1.16"},
+				},
+				User: "vscode",
+				Env:  map[string]string{"WORKSPACE": dir},
+				Mounts: []Mount{
+					{Type: "bind", Source: dir, Target: "/workspace"},
+					{Type: "volume", Source: "cache", Target: "/cache"},
+				},
+				Ports:         []int{3000},
+				SetupCommands: [][]string{{"/bin/sh", "-c", "make deps"}},
+				Unsupported:   []string{"customizations", "features", "forwardPorts[db:5432]"},
+			},
+		},
+		{
+			name: "test happy path with absolute build paths",
+			path: filepath.Join(dir, "absolute"),
+			want: DevContainer{
+				Path: filepath.Join(dir, "absolute", ".devcontainer", "devcontainer.json"),
+				Build: &Build{
+					Context:    filepath.Join(dir, "absolute"),
+					Dockerfile: filepath.Join(dir, "absolute", "build", "Dockerfile"),
+				},
+			},
+		},
+		{
+			name: "test happy path with image file",
+			path: filepath.Join(dir, "image", "devcontainer.json"),
+			want: DevContainer{
+				Path:  filepath.Join(dir, "image", "devcontainer.json"),
+				Image: "ubuntu",
+				User:  "root",
+			},
+		},
+		{
+			name:    "test crappy file without image or build",
+			path:    filepath.Join(dir, "empty"),
+			wantErr: true,
+		},
+		{
+			name:    "test crappy missing file",
+			path:    filepath.Join(dir, "missing"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadDevContainer(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadDevContainer() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadDevContainer() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package devbox
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// KeySource identifies where the key encrypting State is kept.
+type KeySource = string
+
+const (
+	// KeySourceKeyring keeps a random key in the OS keyring, with secret-tool
+	// on Linux and security on macOS.
+	KeySourceKeyring KeySource = "keyring"
+
+	// KeySourceAge derives the key from the secret key of an age identity
+	// file.
+	KeySourceAge KeySource = "age"
+)
+
+// KeySources contains the supported key sources.
+var KeySources = []string{KeySourceKeyring, KeySourceAge}
+
+const (
+	// keyringService and keyringAccount identify the key in the OS keyring.
+	keyringService = "devbox"
+	keyringAccount = "state"
+
+	// ageSecretKeyPrefix prefixes the secret keys of age identity files.
+	ageSecretKeyPrefix = "AGE-SECRET-KEY-1"
+
+	// ageKeyContext is the HKDF info separating keys derived from age
+	// identities from any other use of them.
+	ageKeyContext = "devbox state encryption"
+
+	// encryptedPrefix prefixes encrypted field values in the state file.
+	encryptedPrefix = "devbox:enc:v1:"
+)
+
+// Encryption configures the encryption of the sensitive fields of the Box
+// items of State at rest, which are their kubeconfig, description and
+// environment variable values.
+type Encryption struct {
+	// Key is the source of the encryption key.
+	Key KeySource `yaml:"key"`
+
+	// Identity is the absolute path to the age identity file the key is
+	// derived from, if its source is age.
+	Identity string `yaml:"identity"`
+}
+
+// NewEncryption returns a validated Encryption with a key source. The path of
+// any age identity file is made absolute so that it is found from any
+// directory.
+func NewEncryption(key KeySource, identity string) (*Encryption, error) {
+	if identity != "" {
+		var err error
+		if identity, err = homedir.Expand(identity); err != nil {
+			return nil, err
+		}
+		if identity, err = filepath.Abs(identity); err != nil {
+			return nil, err
+		}
+	}
+	encryption := &Encryption{Key: key, Identity: identity}
+	return encryption, encryption.Validate()
+}
+
+// Validate ensures an Encryption has a supported key source.
+func (encryption Encryption) Validate() error {
+	switch encryption.Key {
+	case KeySourceKeyring:
+		if encryption.Identity != "" {
+			return errors.New("age identity requires the age key source")
+		}
+	case KeySourceAge:
+		if encryption.Identity == "" {
+			return errors.New("age key source requires an age identity file")
+		}
+	default:
+		return fmt.Errorf("invalid key source %q, want one of %s", encryption.Key, strings.Join(KeySources, ", "))
+	}
+	return nil
+}
+
+// EnsureKey ensures the encryption key exists, creating a random key in the
+// OS keyring if its source is the keyring and it has none.
+func (encryption Encryption) EnsureKey() error {
+	if encryption.Key != KeySourceKeyring {
+		_, err := encryption.key()
+		return err
+	}
+	if _, err := lookupKeyringKey(); err == nil {
+		return nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	return storeKeyringKey(base64.StdEncoding.EncodeToString(key))
+}
+
+// key returns the 32 byte AES-256 encryption key.
+func (encryption Encryption) key() ([]byte, error) {
+	switch encryption.Key {
+	case KeySourceKeyring:
+		secret, err := lookupKeyringKey()
+		if err != nil {
+			return nil, err
+		}
+		key, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("invalid key in keyring")
+		}
+		return key, nil
+	case KeySourceAge:
+		return ageIdentityKey(encryption.Identity)
+	}
+	return nil, fmt.Errorf("invalid key source %q", encryption.Key)
+}
+
+// lookupKeyringKey returns the encoded key in the OS keyring.
+func lookupKeyringKey() (string, error) {
+	var out []byte
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		out, err = util.OutputCommand("secret-tool", "lookup", "service", keyringService, "account", keyringAccount)
+	case "darwin":
+		out, err = util.OutputCommand("security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w")
+	default:
+		return "", fmt.Errorf("keyring is not supported on %s", runtime.GOOS)
+	}
+	secret := strings.TrimSpace(string(out))
+	if err != nil || secret == "" {
+		return "", errors.New("key not found in keyring")
+	}
+	return secret, nil
+}
+
+// storeKeyringKey stores an encoded key in the OS keyring. The key is passed
+// on stdin so that it is never in command arguments.
+func storeKeyringKey(secret string) error {
+	switch runtime.GOOS {
+	case "linux":
+		return util.ExecCommandWithInput([]byte(secret), "secret-tool", "store", "--label", "devbox state key", "service", keyringService, "account", keyringAccount)
+	case "darwin":
+		command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", keyringService, keyringAccount, secret)
+		return util.ExecCommandWithInput([]byte(command), "security", "-i")
+	}
+	return fmt.Errorf("keyring is not supported on %s", runtime.GOOS)
+}
+
+// ageIdentityKey derives a key with HKDF-SHA256 from the first secret key of
+// an age identity file. The identity is not used to encrypt with age, but is a
+// secret already kept safe by its owner.
+func ageIdentityKey(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, ageSecretKeyPrefix) {
+			key := make([]byte, 32)
+			if _, err := io.ReadFull(hkdf.New(sha256.New, []byte(line), nil, []byte(ageKeyContext)), key); err != nil {
+				return nil, err
+			}
+			return key, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("age identity file %s has no secret key", path)
+}
+
+// encryptString encrypts a value with AES-256-GCM, authenticating the field it
+// is in as additional data, and returns it prefixed and base64 encoded with its
+// nonce. Empty values are left empty.
+func encryptString(key []byte, value string, field []byte) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), field)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptString decrypts a value encrypted by encryptString in the same field.
+// Values without its prefix are returned unchanged, so that plain values edited
+// into the state file are encrypted when it is next saved.
+func decryptString(key []byte, value string, field []byte) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], field)
+	if err != nil {
+		return "", errors.New("cannot decrypt value, wrong key or moved from another field?")
+	}
+	return string(plain), nil
+}
+
+// newGCM returns an AES-GCM cipher of a key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sensitiveField returns the additional data identifying a sensitive field of
+// the Box with an ID, so that encrypted values cannot be swapped between
+// fields or Boxes in the state file.
+func sensitiveField(id BoxID, name string) []byte {
+	return []byte(id + "\x00" + name)
+}
+
+// transformSensitive returns the Box with an ID with its sensitive fields
+// transformed by a function, such as encryptString or decryptString.
+func (box Box) transformSensitive(id BoxID, key []byte, transform func([]byte, string, []byte) (string, error)) (Box, error) {
+	var err error
+	if box.Kubeconfig, err = transform(key, box.Kubeconfig, sensitiveField(id, "kubeconfig")); err != nil {
+		return box, err
+	}
+	if box.Description, err = transform(key, box.Description, sensitiveField(id, "description")); err != nil {
+		return box, err
+	}
+	if box.Env != nil {
+		env := make(map[string]string, len(box.Env))
+		for name, value := range box.Env {
+			if env[name], err = transform(key, value, sensitiveField(id, "env."+name)); err != nil {
+				return box, fmt.Errorf("env %s: %v", name, err)
+			}
+		}
+		box.Env = env
+	}
+	return box, nil
+}
+
+// transformBoxes returns the Boxes of State with their sensitive fields
+// transformed by a function, leaving the Boxes of State unchanged.
+func (boxes State) transformBoxes(transform func([]byte, string, []byte) (string, error)) (Boxes, error) {
+	key, err := boxes.Encryption.key()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get %s encryption key: %v", boxes.Encryption.Key, err)
+	}
+	transformed := make(Boxes, len(boxes.Boxes))
+	for id, box := range boxes.Boxes {
+		if transformed[id], err = box.transformSensitive(id, key, transform); err != nil {
+			return nil, fmt.Errorf("devbox %s: %v", id, err)
+		}
+	}
+	return transformed, nil
+}
@@ -0,0 +1,147 @@
+package devbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestState_Encryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devbox-encryption")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	identity := filepath.Join(dir, "identity.txt")
+	content := "# created: 2021-06-01T12:00:00Z\n# public key: age1example\nAGE-SECRET-KEY-1EXAMPLEEXAMPLEEXAMPLEEXAMPLEEXAMPLEEXAMPLEEXAMPLEEXAMPLE\n"
+	if err := ioutil.WriteFile(identity, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	encryption, err := NewEncryption(KeySourceAge, identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "state.yaml")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := NewState(path)
+	state.Encryption = encryption
+	box := Box{
+		Image:       "ubuntu",
+		Name:        "devbox-eks",
+		Namespace:   "dev",
+		Kubeconfig:  "/home/alice/.kube/prod-eks",
+		Description: "production debugging",
+		Env:         map[string]string{"GITHUB_TOKEN": "ghp_secret", "EMPTY": ""},
+	}
+	if err := state.AddDevbox("eks", box); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(state.Boxes["eks"], box) {
+		t.Errorf("AddDevbox() changed box to %+v", state.Boxes["eks"])
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plain := range []string{box.Kubeconfig, box.Description, "ghp_secret"} {
+		if strings.Contains(string(buf), plain) {
+			t.Errorf("state file contains plain %q", plain)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("state file mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Boxes["eks"], box) {
+		t.Errorf("LoadState() box = %+v, want %+v", loaded.Boxes["eks"], box)
+	}
+
+	// A different identity cannot decrypt the state.
+	if err := ioutil.WriteFile(identity, []byte("AGE-SECRET-KEY-1OTHER\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadState(path); err == nil {
+		t.Errorf("LoadState() with other identity error = nil, want error")
+	}
+}
+
+func Test_decryptString(t *testing.T) {
+	key := make([]byte, 32)
+	field := sensitiveField("eks", "kubeconfig")
+	encrypted, err := encryptString(key, "/home/alice/.kube/prod-eks", field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		value   string
+		field   []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "test happy path plain value",
+			value: "/home/alice/.kube/config",
+			field: field,
+			want:  "/home/alice/.kube/config",
+		},
+		{
+			name:  "test happy path encrypted value",
+			value: encrypted,
+			field: field,
+			want:  "/home/alice/.kube/prod-eks",
+		},
+		{
+			name:    "test crappy value moved to another field",
+			value:   encrypted,
+			field:   sensitiveField("eks", "description"),
+			wantErr: true,
+		},
+		{
+			name:    "test crappy value moved to another devbox",
+			value:   encrypted,
+			field:   sensitiveField("gke", "kubeconfig"),
+			wantErr: true,
+		},
+		{
+			name:    "test crappy invalid encoding",
+			value:   encryptedPrefix + "not base64!",
+			field:   field,
+			wantErr: true,
+		},
+		{
+			name:    "test crappy truncated value",
+			value:   encryptedPrefix + "AAAA",
+			field:   field,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptString(key, tt.value, tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decryptString() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("decryptString() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
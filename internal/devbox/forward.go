@@ -0,0 +1,377 @@
+package devbox
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// gitCredentialSocket is the path of the socket in a Box forwarding git
+// credentials that its git credential helper connects to.
+const gitCredentialSocket = "/tmp/devbox-git-credential.sock"
+
+// gitCredentialHelper is the git credential helper of a Box forwarding git
+// credentials. It sends its operation and the credential attributes provided
+// by git to the socket, terminated by a blank line, and outputs the response.
+var gitCredentialHelper = fmt.Sprintf(`!f() { { echo "$1"; cat; echo; } | socat -t 60 - UNIX-CONNECT:%s; }; f`, gitCredentialSocket)
+
+// gitCredentialOps maps git credential helper operations to the git
+// credential commands performing them on the host. Only credentials are got,
+// as any process in a devbox can use the relay, and must not be able to store
+// or erase the credentials of the host.
+var gitCredentialOps = map[string]string{
+	"get": "fill",
+}
+
+// ignoredGitCredentialOps contains the git credential helper operations that
+// are not relayed, but accepted so that git in a devbox does not fail.
+var ignoredGitCredentialOps = []string{"store", "erase"}
+
+// startForwarding starts relaying the local agents and credentials forwarded
+// to a Box for the duration of a shell session, and returns the environment
+// variables of the session using them and a function stopping the relays.
+func (box Box) startForwarding() ([]string, func(), error) {
+	var env []string
+	var stops []func()
+	stop := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+	relay := func(socket string, handle func(io.Reader, io.Writer) error) error {
+		stopRelay, err := box.startRelay(socket, handle)
+		if err != nil {
+			stop()
+			return err
+		}
+		stops = append(stops, stopRelay)
+		return nil
+	}
+
+	// Docker devboxes bind mount the SSH agent socket instead, which Docker
+	// Desktop provides on macOS. The GPG agent socket is relayed in all
+	// devboxes, as Docker Desktop provides none and bind mounting it would
+	// create the GPG home directory of the devbox user as root.
+	if box.ForwardSSHAgent && box.Namespace != "" {
+		local, err := localSSHAgentSocket()
+		if err != nil {
+			return nil, nil, err
+		}
+		err = relay(sshAgentSocket, func(r io.Reader, w io.Writer) error {
+			return relayAgentMessages(r, w, func() (net.Conn, error) {
+				return net.Dial("unix", local)
+			})
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		env = append(env, fmt.Sprintf("SSH_AUTH_SOCK=%s", sshAgentSocket))
+	}
+	if box.ForwardGPGAgent {
+		local, err := localGPGAgentSocket()
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		err = relay(box.gpgAgentSocket(), func(r io.Reader, w io.Writer) error {
+			return relayConnection(r, w, local)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if box.ForwardGitCredentials {
+		err := relay(gitCredentialSocket, func(r io.Reader, w io.Writer) error {
+			return handleGitCredential(r, w, runGitCredential)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		// The helper replaces any others configured, such as those of a host
+		// git config copied by setup, which are of no use in the devbox.
+		env = append(env,
+			"GIT_CONFIG_COUNT=2",
+			"GIT_CONFIG_KEY_0=credential.helper",
+			"GIT_CONFIG_VALUE_0=",
+			"GIT_CONFIG_KEY_1=credential.helper",
+			fmt.Sprintf("GIT_CONFIG_VALUE_1=%s", gitCredentialHelper),
+		)
+	}
+	return env, stop, nil
+}
+
+// startRelay relays connections to a socket in a Box over the stdio of exec
+// streams to handle, and returns a function stopping the relay. Containers
+// cannot connect to the host, so socat in the devbox listens on the socket and
+// relays its connections over the stdio of the stream. Each stream relays a
+// single connection, as forked socat processes would share its stdio and mix
+// up the messages of concurrent connections.
+func (box Box) startRelay(socket string, handle func(io.Reader, io.Writer) error) (func(), error) {
+	name, args := box.execStreamArgs("sh", "-c", box.relayScript(socket))
+	if config.DryRun || config.Verbose {
+		fmt.Printf("cmd: %s %s &\n", name, strings.Join(args, " "))
+		if config.DryRun {
+			return func() {}, nil
+		}
+	}
+
+	var mutex sync.Mutex
+	cmds := map[*exec.Cmd]bool{}
+	stopped := false
+	start := func() (*relayStream, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if stopped {
+			return nil, io.EOF
+		}
+		cmd := exec.Command(name, args...)
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		cmds[cmd] = true
+		wait := func() error {
+			err := cmd.Wait()
+			mutex.Lock()
+			defer mutex.Unlock()
+			delete(cmds, cmd)
+			return err
+		}
+		return &relayStream{Reader: stdout, WriteCloser: stdin, wait: wait}, nil
+	}
+	report := func(handleErr error, waitErr error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch {
+		case stopped:
+		case waitErr != nil:
+			fmt.Printf("warning: relay of %s to devbox %s failed, check that socat is installed in it: %v\n", socket, box.Name, waitErr)
+		case handleErr != nil && handleErr != io.EOF:
+			fmt.Printf("warning: cannot relay %s to devbox %s: %v\n", socket, box.Name, handleErr)
+		}
+	}
+
+	go relayStreams(start, handle, report)
+
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		stopped = true
+		for cmd := range cmds {
+			_ = cmd.Process.Kill()
+		}
+	}, nil
+}
+
+// relayScript returns the script listening on a socket in a Box and relaying
+// a connection to it over stdio. Sockets in the home directory of the devbox
+// user, such as that of the GPG agent, and their directory must be owned by
+// it, which they are not if exec runs as root.
+func (box Box) relayScript(socket string) string {
+	dir := path.Dir(socket)
+	listen := fmt.Sprintf("UNIX-LISTEN:%s,unlink-early,mode=600", socket)
+	script := fmt.Sprintf("mkdir -p -m 700 %s", dir)
+	if strings.HasPrefix(dir, box.HomeDir()+"/") {
+		script += fmt.Sprintf(` && if [ "$(id -u)" = 0 ]; then chown %s: %s && set -- ,user=%s; fi`, box.User, dir, box.User)
+		listen += "$1"
+	}
+	return fmt.Sprintf("%s && exec socat %s STDIO", script, listen)
+}
+
+// relayStream is a stream relaying a single connection to a socket in a Box.
+type relayStream struct {
+	io.Reader
+	io.WriteCloser
+
+	// wait waits for the stream to end.
+	wait func() error
+}
+
+// relayStreams handles the connections relayed by streams started by start,
+// until it returns io.EOF. Each stream starts its successor once its
+// connection is accepted, which is when the stream is first read from, so that
+// connections are relayed concurrently, or once it ends without a connection.
+// Serving stops if a stream cannot be started or fails. The errors of each
+// stream are reported when it ends.
+func relayStreams(start func() (*relayStream, error), handle func(io.Reader, io.Writer) error, report func(handleErr error, waitErr error)) {
+	stream, err := start()
+	if err == io.EOF {
+		return
+	}
+	if err != nil {
+		report(err, nil)
+		return
+	}
+	var once sync.Once
+	next := func() {
+		once.Do(func() {
+			go relayStreams(start, handle, report)
+		})
+	}
+	err = handle(acceptReader{Reader: stream.Reader, accept: next}, stream.WriteCloser)
+	stream.WriteCloser.Close()
+	waitErr := stream.wait()
+	report(err, waitErr)
+	if waitErr == nil {
+		next()
+	}
+}
+
+// acceptReader calls accept when data is first read from a stream, as its
+// connection has then been accepted.
+type acceptReader struct {
+	io.Reader
+	accept func()
+}
+
+// Read reads from the stream, calling accept if data was read.
+func (r acceptReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.accept()
+	}
+	return n, err
+}
+
+// relayConnection relays a stream to a new connection to a local socket until
+// either end closes. The connection is made before the stream is read, as the
+// server may speak first.
+func relayConnection(r io.Reader, w io.Writer, socket string) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(w, conn)
+		close(done)
+	}()
+	_, err = io.Copy(conn, r)
+	conn.Close()
+	<-done
+	return err
+}
+
+// localGPGAgentSocket returns the path to the extra socket of the local GPG
+// agent, which restricts the operations available to remote clients.
+func localGPGAgentSocket() (string, error) {
+	out, err := util.OutputCommand("gpgconf", "--list-dirs", "agent-extra-socket")
+	if err != nil {
+		return "", fmt.Errorf("cannot find GPG agent socket: %v", err)
+	}
+	socket := strings.TrimSpace(string(out))
+	if socket == "" {
+		return "", fmt.Errorf("cannot find GPG agent socket")
+	}
+	return socket, nil
+}
+
+// gpgAgentSocket returns the path of the GPG agent socket in a Box, which is
+// the standard socket of the devbox user, so that gpg uses it.
+func (box Box) gpgAgentSocket() string {
+	return path.Join(box.HomeDir(), ".gnupg", "S.gpg-agent")
+}
+
+// ImportGPGPublicKeys imports the local GPG public keys in a Box, which gpg
+// requires to sign with the secret keys of a forwarded GPG agent.
+func (box Box) ImportGPGPublicKeys() error {
+	keys, err := util.OutputCommand("gpg", "--export")
+	if err != nil {
+		return fmt.Errorf("cannot export GPG public keys: %v", err)
+	}
+	name, args := box.execStreamArgs("gpg", "--batch", "--import")
+	return util.ExecCommandWithInput(keys, name, args...)
+}
+
+// handleGitCredential handles a request of the git credential helper of a
+// Box, which is its operation followed by credential attributes terminated by
+// a blank line, by running the git credential command performing it with run
+// and writing its output for get operations.
+func handleGitCredential(r io.Reader, w io.Writer, run func(op string, input []byte) ([]byte, error)) error {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return scanner.Err()
+	}
+	action := strings.TrimSpace(scanner.Text())
+	if util.ContainsString(ignoredGitCredentialOps, action) {
+		return nil
+	}
+	op, ok := gitCredentialOps[action]
+	if !ok {
+		return fmt.Errorf("unknown git credential operation %q", action)
+	}
+	var input bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if !strings.Contains(line, "=") {
+			return fmt.Errorf("malformed git credential attribute %q", line)
+		}
+		input.WriteString(line)
+		input.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	out, err := run(op, input.Bytes())
+	if err != nil {
+		return err
+	}
+	if action == "get" {
+		_, err = w.Write(out)
+	}
+	return err
+}
+
+// runGitCredential runs a local git credential command with input. Prompting
+// is disabled, as the terminal is in use by the devbox shell, so that git in
+// the devbox prompts instead if no credentials are found. Other failures are
+// errors.
+func runGitCredential(op string, input []byte) ([]byte, error) {
+	if config.Verbose {
+		fmt.Printf("cmd: git credential %s\n", op)
+	}
+	cmd := exec.Command("git", "credential", op)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if isNoGitCredentials(stderr.String()) {
+			// No credentials were found, which is not an error of the relay.
+			return nil, nil
+		}
+		return nil, fmt.Errorf("git credential %s failed: %v: %s", op, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// isNoGitCredentials tests if git credential fill failed as no credentials
+// were found, from its error output, as it then tries to prompt for them.
+func isNoGitCredentials(stderr string) bool {
+	return strings.Contains(stderr, "terminal prompts disabled") ||
+		strings.Contains(stderr, "could not read Username") ||
+		strings.Contains(stderr, "could not read Password")
+}
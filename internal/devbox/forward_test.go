@@ -0,0 +1,197 @@
+package devbox
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_handleGitCredential(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   string
+		wantOp    string
+		wantInput string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "test happy path",
+			request:   "get\nprotocol=https\nhost=github.com\n\n",
+			wantOp:    "fill",
+			wantInput: "protocol=https\nhost=github.com\n",
+			want:      "username=dev\npassword=token\n",
+		},
+		{
+			name:    "test happy path store ignored",
+			request: "store\nprotocol=https\nhost=github.com\nusername=dev\npassword=token\n\n",
+		},
+		{
+			name:    "test happy path erase ignored",
+			request: "erase\nprotocol=https\nhost=github.com",
+		},
+		{
+			name:      "test happy path request without blank line",
+			request:   "get\nprotocol=https\nhost=github.com",
+			wantOp:    "fill",
+			wantInput: "protocol=https\nhost=github.com\n",
+			want:      "username=dev\npassword=token\n",
+		},
+		{
+			name:    "test crappy unknown operation",
+			request: "fill\nprotocol=https\n\n",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy malformed attribute",
+			request: "get\nprotocol\n\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotOp, gotInput string
+			run := func(op string, input []byte) ([]byte, error) {
+				gotOp, gotInput = op, string(input)
+				return []byte("username=dev\npassword=token\n"), nil
+			}
+			var response bytes.Buffer
+			err := handleGitCredential(strings.NewReader(tt.request), &response, run)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handleGitCredential() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotOp != tt.wantOp {
+				t.Errorf("handleGitCredential() ran op = %q, want %q", gotOp, tt.wantOp)
+			}
+			if gotInput != tt.wantInput {
+				t.Errorf("handleGitCredential() ran input = %q, want %q", gotInput, tt.wantInput)
+			}
+			if response.String() != tt.want {
+				t.Errorf("handleGitCredential() response = %q, want %q", response.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_isNoGitCredentials(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   bool
+	}{
+		{
+			name:   "test happy path no credentials",
+			stderr: "fatal: could not read Username for 'https://github.com': terminal prompts disabled\n",
+			want:   true,
+		},
+		{
+			name:   "test crappy helper failure",
+			stderr: "fatal: credential helper 'osxkeychain' not found\n",
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNoGitCredentials(tt.stderr); got != tt.want {
+				t.Errorf("isNoGitCredentials() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_relayStreams(t *testing.T) {
+	// Each stream started is a pipe, whose other end is a client connection.
+	clients := make(chan net.Conn, 3)
+	started := 0
+	var mutex sync.Mutex
+	start := func() (*relayStream, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if started == 2 {
+			return nil, io.EOF
+		}
+		started++
+		client, server := net.Pipe()
+		clients <- client
+		return &relayStream{Reader: server, WriteCloser: server, wait: func() error { return nil }}, nil
+	}
+	handle := func(r io.Reader, w io.Writer) error {
+		return relayAgentMessages(r, w, dialReversingAgent)
+	}
+	report := func(handleErr error, waitErr error) {
+		if handleErr != nil || waitErr != nil {
+			t.Errorf("relayStreams() errors = %v, %v", handleErr, waitErr)
+		}
+	}
+	go relayStreams(start, handle, report)
+
+	next := func() net.Conn {
+		select {
+		case client := <-clients:
+			_ = client.SetDeadline(time.Now().Add(5 * time.Second))
+			return client
+		case <-time.After(5 * time.Second):
+			t.Fatal("relayStreams() did not start stream")
+			return nil
+		}
+	}
+
+	// The first client sends a request and waits for its response while the
+	// second client, accepted concurrently by another stream, is served.
+	first := next()
+	if _, err := first.Write(agentMessage("first")); err != nil {
+		t.Fatal(err)
+	}
+	second := next()
+	if _, err := second.Write(agentMessage("second")); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		client net.Conn
+		want   string
+	}{
+		{second, "dnoces"},
+		{first, "tsrif"},
+	} {
+		response, err := readAgentMessage(tt.client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(response[4:]); got != tt.want {
+			t.Errorf("relayStreams() response = %q, want %q", got, tt.want)
+		}
+		tt.client.Close()
+	}
+}
+
+func TestBox_relayScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		socket string
+		want   string
+	}{
+		{
+			name:   "test happy path tmp socket",
+			socket: sshAgentSocket,
+			want:   "mkdir -p -m 700 /tmp && exec socat UNIX-LISTEN:/tmp/devbox-ssh-agent.sock,unlink-early,mode=600 STDIO",
+		},
+		{
+			name:   "test happy path home socket owned by user",
+			socket: "/home/developer/.gnupg/S.gpg-agent",
+			want:   `mkdir -p -m 700 /home/developer/.gnupg && if [ "$(id -u)" = 0 ]; then chown developer: /home/developer/.gnupg && set -- ,user=developer; fi && exec socat UNIX-LISTEN:/home/developer/.gnupg/S.gpg-agent,unlink-early,mode=600$1 STDIO`,
+		},
+	}
+	box := Box{User: "developer"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := box.relayScript(tt.socket); got != tt.want {
+				t.Errorf("relayScript() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package devbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// Verification configures the verification of the signature of the image of a
+// Box before it is started.
+type Verification struct {
+	// Key is the absolute path to the cosign public key the image signature
+	// is verified with.
+	Key string `yaml:"key"`
+
+	// Required refuses to start the devbox if its image signature cannot be
+	// verified, rather than warning.
+	Required bool `yaml:"required"`
+}
+
+// NewVerification returns a validated Verification of a public key file. The
+// path is made absolute so that the key is found from any directory.
+func NewVerification(key string, required bool) (*Verification, error) {
+	key, err := homedir.Expand(key)
+	if err != nil {
+		return nil, err
+	}
+	if key, err = filepath.Abs(key); err != nil {
+		return nil, err
+	}
+	verification := &Verification{Key: key, Required: required}
+	return verification, verification.Validate()
+}
+
+// Validate ensures a Verification has an existing public key file.
+func (verification Verification) Validate() error {
+	if verification.Key == "" {
+		return errors.New("verification public key cannot be empty")
+	}
+	if !util.FileExists(verification.Key) {
+		return fmt.Errorf("verification public key %s not found", verification.Key)
+	}
+	return nil
+}
+
+// ResolveDigest pulls an image and returns the digest of its repository,
+// which pins its current contents.
+func ResolveDigest(image string) (string, error) {
+	if strings.Contains(image, "@") {
+		return image[strings.Index(image, "@")+1:], nil
+	}
+	if err := util.ExecCommand("docker", "pull", image); err != nil {
+		return "", err
+	}
+	out, err := util.OutputCommand("docker", "image", "inspect", "--format", "{{range .RepoDigests}}{{println .}}{{end}}", image)
+	if err != nil {
+		return "", err
+	}
+	return matchRepoDigest(image, strings.Fields(string(out)))
+}
+
+// matchRepoDigest returns the digest of the repository digest of an image.
+// Repository digests of images without a registry host omit it, as do those
+// of official images their library path.
+func matchRepoDigest(image string, repoDigests []string) (string, error) {
+	repository := normalizeRepository(imageRepository(image))
+	for _, repoDigest := range repoDigests {
+		parts := strings.SplitN(repoDigest, "@", 2)
+		if len(parts) == 2 && normalizeRepository(parts[0]) == repository {
+			return parts[1], nil
+		}
+	}
+	return "", fmt.Errorf("image %s has no repository digest", image)
+}
+
+// imageRepository returns the repository of an image, without its tag or
+// digest.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	// A colon after the last slash separates the tag, others a host port.
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// normalizeRepository returns the repository of an image including its
+// registry host.
+func normalizeRepository(repository string) string {
+	host := RegistryHost(repository)
+	if host == dockerHubHost {
+		if strings.HasPrefix(repository, "docker.io/") || strings.HasPrefix(repository, "index.docker.io/") {
+			repository = repository[strings.Index(repository, "/")+1:]
+		}
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+		return fmt.Sprintf("%s/%s", dockerHubHost, repository)
+	}
+	return repository
+}
+
+// imageRef returns the reference of the image of a Box run when started, which
+// is pinned to its digest if set, or else to the digest verified when started.
+func (box Box) imageRef() string {
+	digest := box.Digest
+	if digest == "" {
+		digest = box.verifiedDigest
+	}
+	if digest == "" {
+		return box.Image
+	}
+	return fmt.Sprintf("%s@%s", imageRepository(box.Image), digest)
+}
+
+// verifyImage verifies the signature of the image of a Box with cosign, if
+// configured, and returns the Box with the verified digest, so that the image
+// run is the one verified even if its tag has since moved. Failures are errors
+// if verification is required, and warnings otherwise. Images are verified by
+// digest when pinned.
+func (box Box) verifyImage() (Box, error) {
+	if box.Verification == nil {
+		return box, nil
+	}
+	args := []string{"verify", "--key", box.Verification.Key, box.imageRef()}
+	if config.DryRun {
+		fmt.Printf("cmd: cosign %s\n", strings.Join(args, " "))
+		return box, nil
+	}
+	if config.Verbose {
+		fmt.Printf("msg: verifying image %s of devbox %s\n", box.imageRef(), box.Name)
+	}
+	out, err := util.OutputCommand("cosign", args...)
+	if err == nil {
+		var digest string
+		if digest, err = verifiedDigest(out); err == nil && box.Digest != "" && digest != box.Digest {
+			err = fmt.Errorf("verified digest %s is not pinned digest %s", digest, box.Digest)
+		}
+		if err == nil {
+			box.verifiedDigest = digest
+			return box, nil
+		}
+	}
+	if box.Verification.Required {
+		return box, fmt.Errorf("cannot verify image %s of devbox %s: %v", box.imageRef(), box.Name, err)
+	}
+	fmt.Printf("warning: cannot verify image %s of devbox %s: %v\n", box.imageRef(), box.Name, err)
+	return box, nil
+}
+
+// verifiedDigest returns the image digest of the signatures verified by cosign
+// verify, which outputs their payloads as a JSON array.
+func verifiedDigest(out []byte) (string, error) {
+	var payloads []struct {
+		Critical struct {
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+		} `json:"critical"`
+	}
+	if err := json.Unmarshal(out, &payloads); err != nil {
+		return "", fmt.Errorf("invalid cosign output: %v", err)
+	}
+	digest := ""
+	for _, payload := range payloads {
+		d := payload.Critical.Image.DockerManifestDigest
+		if d == "" || (digest != "" && d != digest) {
+			return "", errors.New("cosign verified no single image digest")
+		}
+		digest = d
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		return "", errors.New("cosign verified no image digest")
+	}
+	return digest, nil
+}
@@ -0,0 +1,131 @@
+package devbox
+
+import "testing"
+
+func Test_matchRepoDigest(t *testing.T) {
+	repoDigests := []string{
+		"ubuntu@sha256:1111",
+		"mojochao/devbox-base@sha256:2222",
+		"localhost:5000/devbox@sha256:3333",
+	}
+	tests := []struct {
+		name    string
+		image   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "test happy path",
+			image: "localhost:5000/devbox:latest",
+			want:  "sha256:3333",
+		},
+		{
+			name:  "test happy path official image",
+			image: "docker.io/library/ubuntu:22.04",
+			want:  "sha256:1111",
+		},
+		{
+			name:  "test happy path docker hub image",
+			image: "mojochao/devbox-base",
+			want:  "sha256:2222",
+		},
+		{
+			name:    "test crappy unknown repository",
+			image:   "ghcr.io/mojochao/devbox-base:latest",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchRepoDigest(tt.image, repoDigests)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("matchRepoDigest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("matchRepoDigest() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_imageRef(t *testing.T) {
+	tests := []struct {
+		name string
+		box  Box
+		want string
+	}{
+		{
+			name: "test happy path",
+			box:  Box{Image: "localhost:5000/devbox:1.0", Digest: "sha256:3333"},
+			want: "localhost:5000/devbox@sha256:3333",
+		},
+		{
+			name: "test happy path unpinned",
+			box:  Box{Image: "localhost:5000/devbox:1.0"},
+			want: "localhost:5000/devbox:1.0",
+		},
+		{
+			name: "test happy path verified",
+			box:  Box{Image: "localhost:5000/devbox:1.0", verifiedDigest: "sha256:4444"},
+			want: "localhost:5000/devbox@sha256:4444",
+		},
+		{
+			name: "test happy path untagged",
+			box:  Box{Image: "localhost:5000/devbox", Digest: "sha256:3333"},
+			want: "localhost:5000/devbox@sha256:3333",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.box.imageRef(); got != tt.want {
+				t.Errorf("imageRef() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_verifiedDigest(t *testing.T) {
+	payload := func(digest string) string {
+		return `{"critical":{"identity":{"docker-reference":"ghcr.io/mojochao/devbox"},"image":{"docker-manifest-digest":"` + digest + `"},"type":"cosign container image signature"},"optional":null}`
+	}
+	tests := []struct {
+		name    string
+		out     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "test happy path",
+			out:  "[" + payload("sha256:3333") + "," + payload("sha256:3333") + "]\n",
+			want: "sha256:3333",
+		},
+		{
+			name:    "test crappy different digests",
+			out:     "[" + payload("sha256:3333") + "," + payload("sha256:4444") + "]",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy no signatures",
+			out:     "[]",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy invalid output",
+			out:     "Verification for ghcr.io/mojochao/devbox",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifiedDigest([]byte(tt.out))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifiedDigest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("verifiedDigest() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
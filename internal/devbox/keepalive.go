@@ -0,0 +1,51 @@
+package devbox
+
+import "errors"
+
+// KeepAlive overrides the entrypoint of a Box image with a long-running
+// command, so that images whose default command exits immediately can be
+// used as devboxes.
+type KeepAlive struct {
+	// Command replaces the image entrypoint.
+	Command []string `yaml:"command"`
+
+	// Args are the arguments of Command, replacing the image command.
+	Args []string `yaml:"args"`
+}
+
+// DefaultKeepAlive sleeps forever in a shell that exits promptly when the
+// devbox is stopped, as shells running as PID 1 otherwise ignore SIGTERM.
+var DefaultKeepAlive = KeepAlive{
+	Command: []string{"/bin/sh", "-c"},
+	Args:    []string{"trap 'exit 0' TERM INT; while true; do sleep 3600 & wait $!; done"},
+}
+
+// NewKeepAlive returns a KeepAlive, with the DefaultKeepAlive used if no
+// command and args are provided. Args require a command, as they would
+// otherwise be split across the arguments of the DefaultKeepAlive shell.
+func NewKeepAlive(command []string, args []string) (*KeepAlive, error) {
+	if len(command) == 0 {
+		if len(args) > 0 {
+			return nil, errors.New("keep-alive args require a keep-alive command")
+		}
+		keepAlive := DefaultKeepAlive
+		return &keepAlive, nil
+	}
+	return &KeepAlive{
+		Command: command,
+		Args:    args,
+	}, nil
+}
+
+// dockerArgs returns the docker run arguments preceding the image that
+// override its entrypoint.
+func (keepAlive KeepAlive) dockerArgs() []string {
+	return []string{"--init", "--entrypoint", keepAlive.Command[0]}
+}
+
+// dockerCommand returns the docker run arguments following the image that
+// override its command.
+func (keepAlive KeepAlive) dockerCommand() []string {
+	command := append([]string{}, keepAlive.Command[1:]...)
+	return append(command, keepAlive.Args...)
+}
@@ -0,0 +1,59 @@
+package devbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewKeepAlive(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		args    []string
+		want    *KeepAlive
+		wantErr bool
+	}{
+		{
+			name: "test default keep-alive",
+			want: &DefaultKeepAlive,
+		},
+		{
+			name:    "test custom command without args",
+			command: []string{"sleep", "infinity"},
+			want:    &KeepAlive{Command: []string{"sleep", "infinity"}},
+		},
+		{
+			name:    "test custom command with args",
+			command: []string{"tini", "--"},
+			args:    []string{"sleep", "infinity"},
+			want:    &KeepAlive{Command: []string{"tini", "--"}, Args: []string{"sleep", "infinity"}},
+		},
+		{
+			name:    "test crappy args without command",
+			args:    []string{"sleep", "infinity"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewKeepAlive(tt.command, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewKeepAlive() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewKeepAlive() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeepAlive_dockerCommand(t *testing.T) {
+	keepAlive := KeepAlive{Command: []string{"tini", "--"}, Args: []string{"sleep", "infinity"}}
+	if got, want := keepAlive.dockerArgs(), []string{"--init", "--entrypoint", "tini"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dockerArgs() got = %v, want %v", got, want)
+	}
+	if got, want := keepAlive.dockerCommand(), []string{"--", "sleep", "infinity"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dockerCommand() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package devbox
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// LogOptions contains options selecting the logs of a Box to show.
+type LogOptions struct {
+	// Follow streams new logs until interrupted.
+	Follow bool
+
+	// Since shows only logs newer than a relative duration like "10m", or
+	// an RFC3339 timestamp.
+	Since string
+
+	// Tail shows only the number of most recent lines, or all lines if
+	// negative.
+	Tail int
+
+	// Previous shows the logs of the previous container of a restarted
+	// Kubernetes devbox pod.
+	Previous bool
+}
+
+// Logs shows the logs of a Box container or pod.
+func (box Box) Logs(opts LogOptions) error {
+	if config.Verbose {
+		fmt.Printf("msg: showing logs of devbox %s\n", box.Name)
+	}
+	name, args, err := box.logsCommand(opts)
+	if err != nil {
+		return err
+	}
+	return util.ExecCommand(name, args...)
+}
+
+// logsCommand returns the command and arguments showing the logs of a Box.
+// Docker devbox containers are kept when they exit, so their logs are shown
+// without the previous option.
+func (box Box) logsCommand(opts LogOptions) (string, []string, error) {
+	if box.Namespace == "" {
+		if opts.Previous {
+			return "", nil, errors.New("previous container logs are only available for Kubernetes devboxes, the logs of exited Docker devboxes are shown without it")
+		}
+		args := []string{"logs"}
+		if opts.Follow {
+			args = append(args, "--follow")
+		}
+		if opts.Since != "" {
+			args = append(args, fmt.Sprintf("--since=%s", opts.Since))
+		}
+		if opts.Tail >= 0 {
+			args = append(args, fmt.Sprintf("--tail=%d", opts.Tail))
+		}
+		args = append(args, box.Name)
+		return "docker", args, nil
+	}
+
+	args := []string{"logs", box.podName()}
+	args = append(args, box.containerArgs()...)
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Since != "" {
+		// kubectl accepts relative durations and timestamps in separate flags.
+		if _, err := time.ParseDuration(opts.Since); err == nil {
+			args = append(args, fmt.Sprintf("--since=%s", opts.Since))
+		} else if _, err := time.Parse(time.RFC3339, opts.Since); err == nil {
+			args = append(args, fmt.Sprintf("--since-time=%s", opts.Since))
+		} else {
+			return "", nil, fmt.Errorf("invalid since %q, want duration or RFC3339 timestamp", opts.Since)
+		}
+	}
+	args = append(args, "--tail="+strconv.Itoa(opts.Tail))
+	if opts.Previous {
+		args = append(args, "--previous")
+	}
+	return "kubectl", box.kubectlArgs(args...), nil
+}
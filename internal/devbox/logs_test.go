@@ -0,0 +1,80 @@
+package devbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBox_logsCommand(t *testing.T) {
+	docker := Box{Name: "devbox"}
+	kubernetes := Box{Name: "devbox", Namespace: "dev", Kubeconfig: "/kube/config"}
+	attached := Box{Name: "devbox", Namespace: "dev", Kubeconfig: "/kube/config", Attachment: &Attachment{Mode: AttachEphemeral, Pod: "web"}}
+	tests := []struct {
+		name     string
+		box      Box
+		opts     LogOptions
+		wantName string
+		wantArgs []string
+		wantErr  bool
+	}{
+		{
+			name:     "test happy path docker",
+			box:      docker,
+			opts:     LogOptions{Follow: true, Since: "10m", Tail: 100},
+			wantName: "docker",
+			wantArgs: []string{"logs", "--follow", "--since=10m", "--tail=100", "devbox"},
+		},
+		{
+			name:     "test happy path docker all lines",
+			box:      docker,
+			opts:     LogOptions{Tail: -1},
+			wantName: "docker",
+			wantArgs: []string{"logs", "devbox"},
+		},
+		{
+			name:    "test crappy docker previous",
+			box:     docker,
+			opts:    LogOptions{Tail: -1, Previous: true},
+			wantErr: true,
+		},
+		{
+			name:     "test happy path kubectl previous",
+			box:      kubernetes,
+			opts:     LogOptions{Tail: -1, Previous: true},
+			wantName: "kubectl",
+			wantArgs: []string{"--kubeconfig=/kube/config", "--namespace=dev", "logs", "devbox", "--tail=-1", "--previous"},
+		},
+		{
+			name:     "test happy path kubectl since time",
+			box:      kubernetes,
+			opts:     LogOptions{Since: "2021-06-01T12:00:00Z", Tail: 10},
+			wantName: "kubectl",
+			wantArgs: []string{"--kubeconfig=/kube/config", "--namespace=dev", "logs", "devbox", "--since-time=2021-06-01T12:00:00Z", "--tail=10"},
+		},
+		{
+			name:     "test happy path kubectl attached",
+			box:      attached,
+			opts:     LogOptions{Follow: true, Since: "1h", Tail: -1},
+			wantName: "kubectl",
+			wantArgs: []string{"--kubeconfig=/kube/config", "--namespace=dev", "logs", "web", "-c", "devbox", "--follow", "--since=1h", "--tail=-1"},
+		},
+		{
+			name:    "test crappy kubectl since",
+			box:     kubernetes,
+			opts:    LogOptions{Since: "yesterday", Tail: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args, err := tt.box.logsCommand(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("logsCommand() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if name != tt.wantName || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("logsCommand() got = %s %v, want %s %v", name, args, tt.wantName, tt.wantArgs)
+			}
+		})
+	}
+}
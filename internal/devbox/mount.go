@@ -0,0 +1,81 @@
+package devbox
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// MountTypes contains the supported Mount types.
+var MountTypes = []string{"bind", "volume", "tmpfs"}
+
+// Mount is a bind mount, volume or tmpfs mounted in a Docker Box.
+type Mount struct {
+	// Type is the mount type, one of bind, volume or tmpfs.
+	Type string `yaml:"type"`
+
+	// Source is the host path of a bind mount or the name of a volume.
+	Source string `yaml:"source"`
+
+	// Target is the path the mount is mounted at in the devbox.
+	Target string `yaml:"target"`
+
+	// ReadOnly mounts the mount read-only.
+	ReadOnly bool `yaml:"readOnly"`
+}
+
+// ParseMount parses a mount in the comma separated key=value format of the
+// docker run --mount flag, for example type=bind,source=/src,target=/src.
+// Keys other than type, source, target and readonly are ignored.
+func ParseMount(s string) (Mount, error) {
+	mount := Mount{Type: "volume"}
+	for _, field := range strings.Split(s, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := ""
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+		switch key {
+		case "type":
+			mount.Type = value
+		case "source", "src":
+			mount.Source = value
+		case "target", "destination", "dst":
+			mount.Target = value
+		case "readonly", "ro":
+			mount.ReadOnly = value == "" || value == "true" || value == "1"
+		}
+	}
+	return mount, mount.Validate()
+}
+
+// Validate ensures a Mount has a valid type, and a target and any source it
+// requires.
+func (mount Mount) Validate() error {
+	if !util.ContainsString(MountTypes, mount.Type) {
+		return fmt.Errorf("invalid mount type %q, want one of %s", mount.Type, strings.Join(MountTypes, ", "))
+	}
+	if mount.Target == "" {
+		return errors.New("mount target cannot be empty")
+	}
+	if mount.Type == "bind" && mount.Source == "" {
+		return fmt.Errorf("bind mount of %s has no source", mount.Target)
+	}
+	return nil
+}
+
+// dockerArg returns the docker run --mount flag of a Mount.
+func (mount Mount) dockerArg() string {
+	fields := []string{fmt.Sprintf("type=%s", mount.Type)}
+	if mount.Source != "" {
+		fields = append(fields, fmt.Sprintf("source=%s", mount.Source))
+	}
+	fields = append(fields, fmt.Sprintf("target=%s", mount.Target))
+	if mount.ReadOnly {
+		fields = append(fields, "readonly")
+	}
+	return fmt.Sprintf("--mount=%s", strings.Join(fields, ","))
+}
@@ -0,0 +1,66 @@
+package devbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMount(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Mount
+		wantErr bool
+	}{
+		{
+			name: "test bind mount",
+			s:    "type=bind,source=/src,target=/workspace,consistency=cached",
+			want: Mount{Type: "bind", Source: "/src", Target: "/workspace"},
+		},
+		{
+			name: "test read-only volume with short keys",
+			s:    "src=cache,dst=/cache,readonly",
+			want: Mount{Type: "volume", Source: "cache", Target: "/cache", ReadOnly: true},
+		},
+		{
+			name: "test tmpfs",
+			s:    "type=tmpfs,target=/tmp",
+			want: Mount{Type: "tmpfs", Target: "/tmp"},
+		},
+		{
+			name:    "test crappy type",
+			s:       "type=nfs,source=/src,target=/src",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy bind mount without source",
+			s:       "type=bind,target=/src",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy mount without target",
+			s:       "type=volume,source=cache",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMount(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseMount() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMount() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMount_dockerArg(t *testing.T) {
+	mount := Mount{Type: "bind", Source: "/src", Target: "/workspace", ReadOnly: true}
+	want := "--mount=type=bind,source=/src,target=/workspace,readonly"
+	if got := mount.dockerArg(); got != want {
+		t.Errorf("dockerArg() got = %v, want %v", got, want)
+	}
+}
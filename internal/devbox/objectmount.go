@@ -0,0 +1,114 @@
+package devbox
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ObjectMount mounts an existing Kubernetes Secret or ConfigMap in the namespace
+// of a Kubernetes Box, so that team secrets and configuration files need not
+// be copied to it.
+type ObjectMount struct {
+	// Name is the name of the Secret or ConfigMap.
+	Name string `yaml:"name"`
+
+	// Key is the key mounted as a file at Path, if set. Otherwise all keys
+	// are mounted as files in the directory at Path.
+	Key string `yaml:"key"`
+
+	// Path is the absolute path the Secret or ConfigMap is mounted at.
+	Path string `yaml:"path"`
+}
+
+// objectNamePattern matches the DNS subdomain names of Kubernetes objects.
+var objectNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// objectKeyPattern matches the keys of Kubernetes Secrets and ConfigMaps.
+var objectKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// ParseObjectMount parses an ObjectMount in NAME[/KEY]:PATH format, for
+// example registry-token:/etc/registry or licenses/ide.key:/opt/ide/license.
+func ParseObjectMount(s string) (ObjectMount, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return ObjectMount{}, fmt.Errorf("invalid mount %q, want NAME[/KEY]:PATH format", s)
+	}
+	mount := ObjectMount{Name: parts[0], Path: parts[1]}
+	if i := strings.Index(mount.Name, "/"); i >= 0 {
+		mount.Key = mount.Name[i+1:]
+		mount.Name = mount.Name[:i]
+	}
+	return mount, mount.Validate()
+}
+
+// Validate ensures an ObjectMount has a valid name, key and path.
+func (mount ObjectMount) Validate() error {
+	if len(mount.Name) > 253 || !objectNamePattern.MatchString(mount.Name) {
+		return fmt.Errorf("invalid mount name %q", mount.Name)
+	}
+	if mount.Key != "" && (len(mount.Key) > 253 || !objectKeyPattern.MatchString(mount.Key)) {
+		return fmt.Errorf("invalid key %q of mount %s", mount.Key, mount.Name)
+	}
+	if !path.IsAbs(mount.Path) {
+		return fmt.Errorf("path %q of mount %s must be absolute", mount.Path, mount.Name)
+	}
+	return nil
+}
+
+// String returns an ObjectMount in NAME[/KEY]:PATH format.
+func (mount ObjectMount) String() string {
+	if mount.Key == "" {
+		return fmt.Sprintf("%s:%s", mount.Name, mount.Path)
+	}
+	return fmt.Sprintf("%s/%s:%s", mount.Name, mount.Key, mount.Path)
+}
+
+// volumeMount returns the read-only volume mount of an ObjectMount of a volume.
+// A single key is mounted with a sub path, so that other files in the
+// directory of its path are kept.
+func (mount ObjectMount) volumeMount(volume string) VolumeMount {
+	volumeMount := VolumeMount{
+		Name:      volume,
+		MountPath: mount.Path,
+		ReadOnly:  true,
+	}
+	if mount.Key != "" {
+		volumeMount.SubPath = mount.Key
+	}
+	return volumeMount
+}
+
+// items returns the keys of an ObjectMount projected into its volume, or none
+// if all keys are.
+func (mount ObjectMount) items() []KeyToPath {
+	if mount.Key == "" {
+		return nil
+	}
+	return []KeyToPath{{Key: mount.Key, Path: mount.Key}}
+}
+
+// objectVolumes returns the pod volumes and container volume mounts of the
+// Secret and ConfigMap mounts of a Kubernetes Box.
+func (box Box) objectVolumes() ([]Volume, []VolumeMount) {
+	var volumes []Volume
+	var volumeMounts []VolumeMount
+	for i, mount := range box.SecretMounts {
+		name := fmt.Sprintf("secret-%d", i)
+		volumes = append(volumes, Volume{
+			Name:   name,
+			Secret: &SecretVolumeSource{SecretName: mount.Name, Items: mount.items()},
+		})
+		volumeMounts = append(volumeMounts, mount.volumeMount(name))
+	}
+	for i, mount := range box.ConfigMapMounts {
+		name := fmt.Sprintf("configmap-%d", i)
+		volumes = append(volumes, Volume{
+			Name:      name,
+			ConfigMap: &ConfigMapVolumeSource{Name: mount.Name, Items: mount.items()},
+		})
+		volumeMounts = append(volumeMounts, mount.volumeMount(name))
+	}
+	return volumes, volumeMounts
+}
@@ -0,0 +1,82 @@
+package devbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseObjectMount(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    ObjectMount
+		wantErr bool
+	}{
+		{
+			name:  "test happy path",
+			input: "registry-token:/etc/registry",
+			want:  ObjectMount{Name: "registry-token", Path: "/etc/registry"},
+		},
+		{
+			name:  "test happy path with key",
+			input: "licenses/ide.key:/opt/ide/license",
+			want:  ObjectMount{Name: "licenses", Key: "ide.key", Path: "/opt/ide/license"},
+		},
+		{
+			name:    "test crappy missing path",
+			input:   "registry-token",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy relative path",
+			input:   "registry-token:etc/registry",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy invalid name",
+			input:   "Registry_Token:/etc/registry",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy invalid key",
+			input:   "licenses/ide key:/opt/ide/license",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseObjectMount(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseObjectMount() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseObjectMount() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_objectVolumes(t *testing.T) {
+	box := Box{
+		Name:            "eks",
+		Namespace:       "devbox",
+		SecretMounts:    []ObjectMount{{Name: "registry-token", Path: "/etc/registry"}},
+		ConfigMapMounts: []ObjectMount{{Name: "licenses", Key: "ide.key", Path: "/opt/ide/license"}},
+	}
+	pod := box.Pod()
+	wantVolumes := []Volume{
+		{Name: "secret-0", Secret: &SecretVolumeSource{SecretName: "registry-token"}},
+		{Name: "configmap-0", ConfigMap: &ConfigMapVolumeSource{Name: "licenses", Items: []KeyToPath{{Key: "ide.key", Path: "ide.key"}}}},
+	}
+	if !reflect.DeepEqual(pod.Spec.Volumes, wantVolumes) {
+		t.Errorf("Pod() volumes = %v, want %v", pod.Spec.Volumes, wantVolumes)
+	}
+	wantMounts := []VolumeMount{
+		{Name: "secret-0", MountPath: "/etc/registry", ReadOnly: true},
+		{Name: "configmap-0", MountPath: "/opt/ide/license", SubPath: "ide.key", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(pod.Spec.Containers[0].VolumeMounts, wantMounts) {
+		t.Errorf("Pod() volume mounts = %v, want %v", pod.Spec.Containers[0].VolumeMounts, wantMounts)
+	}
+}
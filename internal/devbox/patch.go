@@ -0,0 +1,134 @@
+package devbox
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// PodPatch references a patch file applied to the generated pod manifest of
+// a Kubernetes Box before the pod is created.
+type PodPatch struct {
+	// Path is the path to the YAML or JSON patch file.
+	Path string `yaml:"path"`
+
+	// Type is the patch type, one of strategic, merge or json.
+	Type string `yaml:"type"`
+}
+
+// PatchTypes contains the supported pod patch types.
+var PatchTypes = []string{"strategic", "merge", "json"}
+
+// jsonPatchOps contains the valid operations of a RFC 6902 JSON patch.
+var jsonPatchOps = []string{"add", "remove", "replace", "move", "copy", "test"}
+
+// NewPodPatch returns a validated PodPatch of a patch file. Relative paths are
+// made absolute so that the patch can be applied from any directory.
+func NewPodPatch(path string, patchType string) (*PodPatch, error) {
+	if patchType == "" {
+		patchType = "strategic"
+	}
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	patch := &PodPatch{Path: path, Type: patchType}
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// Validate ensures a PodPatch file exists and is well formed for its type.
+// Whether it applies to a pod manifest is only known once it is applied.
+func (patch PodPatch) Validate() error {
+	if !util.ContainsString(PatchTypes, patch.Type) {
+		return fmt.Errorf("invalid patch type %q, want one of %s", patch.Type, strings.Join(PatchTypes, ", "))
+	}
+	buf, err := patch.read()
+	if err != nil {
+		return err
+	}
+	if patch.Type != "json" {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal(buf, &obj); err != nil {
+			return fmt.Errorf("%s patch %s is not an object: %v", patch.Type, patch.Path, err)
+		}
+		return nil
+	}
+	var ops []struct {
+		Op   string `json:"op"`
+		Path string `json:"path"`
+		From string `json:"from"`
+	}
+	if err := yaml.Unmarshal(buf, &ops); err != nil {
+		return fmt.Errorf("json patch %s is not a list of operations: %v", patch.Path, err)
+	}
+	for i, op := range ops {
+		if !util.ContainsString(jsonPatchOps, op.Op) {
+			return fmt.Errorf("json patch %s operation %d has invalid op %q", patch.Path, i, op.Op)
+		}
+		if !strings.HasPrefix(op.Path, "/") {
+			return fmt.Errorf("json patch %s operation %d has invalid path %q", patch.Path, i, op.Path)
+		}
+		if (op.Op == "move" || op.Op == "copy") && !strings.HasPrefix(op.From, "/") {
+			return fmt.Errorf("json patch %s operation %d has invalid from %q", patch.Path, i, op.From)
+		}
+	}
+	return nil
+}
+
+// read returns the patch file contents in JSON format.
+func (patch PodPatch) read() ([]byte, error) {
+	path, err := homedir.Expand(patch.Path)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(buf))) == 0 {
+		return nil, errors.New("patch file is empty")
+	}
+	return yaml.YAMLToJSON(buf)
+}
+
+// apply applies the patch to a pod manifest locally with kubectl, run with
+// kubectlArgs, and returns the patched manifest in YAML format.
+func (patch PodPatch) apply(manifest []byte, kubectlArgs func(args ...string) []string) ([]byte, error) {
+	buf, err := patch.read()
+	if err != nil {
+		return nil, err
+	}
+	file, err := ioutil.TempFile("", "devbox-pod-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(manifest); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+	patched, err := util.OutputCommand("kubectl", kubectlArgs("patch", "--local", "-f", file.Name(),
+		fmt.Sprintf("--type=%s", patch.Type), fmt.Sprintf("--patch=%s", buf), "-o", "yaml")...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot apply %s patch %s: %v", patch.Type, patch.Path, err)
+	}
+	return patched, nil
+}
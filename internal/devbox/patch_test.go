@@ -0,0 +1,80 @@
+package devbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPodPatch_Validate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devbox-patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"strategic.yaml": "spec:\n  hostAliases:\n  - ip: 10.0.0.1\n    hostnames: [db]\n",
+		"json.yaml":      "- op: add\n  path: /spec/securityContext\n  value: {runAsUser: 1000}\n",
+		"badop.yaml":     "- op: frobnicate\n  path: /spec\n",
+		"list.yaml":      "- one\n- two\n",
+		"empty.yaml":     "",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		patch   PodPatch
+		wantErr bool
+	}{
+		{
+			name:  "test happy path with strategic patch",
+			patch: PodPatch{Path: filepath.Join(dir, "strategic.yaml"), Type: "strategic"},
+		},
+		{
+			name:  "test happy path with merge patch",
+			patch: PodPatch{Path: filepath.Join(dir, "strategic.yaml"), Type: "merge"},
+		},
+		{
+			name:  "test happy path with json patch",
+			patch: PodPatch{Path: filepath.Join(dir, "json.yaml"), Type: "json"},
+		},
+		{
+			name:    "test crappy type",
+			patch:   PodPatch{Path: filepath.Join(dir, "strategic.yaml"), Type: "apply"},
+			wantErr: true,
+		},
+		{
+			name:    "test crappy json patch op",
+			patch:   PodPatch{Path: filepath.Join(dir, "badop.yaml"), Type: "json"},
+			wantErr: true,
+		},
+		{
+			name:    "test crappy strategic patch",
+			patch:   PodPatch{Path: filepath.Join(dir, "list.yaml"), Type: "strategic"},
+			wantErr: true,
+		},
+		{
+			name:    "test empty patch",
+			patch:   PodPatch{Path: filepath.Join(dir, "empty.yaml"), Type: "merge"},
+			wantErr: true,
+		},
+		{
+			name:    "test missing patch",
+			patch:   PodPatch{Path: filepath.Join(dir, "nonesuch.yaml"), Type: "merge"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.patch.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package devbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// The types in this file model the subset of the Kubernetes pod API used by
+// devboxes. They use json tags as they are marshaled into pod manifests.
+
+// Pod is a Kubernetes pod manifest.
+type Pod struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   ObjectMeta `json:"metadata"`
+	Spec       PodSpec    `json:"spec"`
+}
+
+// ObjectMeta is Kubernetes object metadata.
+type ObjectMeta struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// PodSpec is a Kubernetes pod spec.
+type PodSpec struct {
+	Containers         []Container            `json:"containers"`
+	RestartPolicy      string                 `json:"restartPolicy,omitempty"`
+	ServiceAccountName string                 `json:"serviceAccountName,omitempty"`
+	NodeSelector       map[string]string      `json:"nodeSelector,omitempty"`
+	Tolerations        []Toleration           `json:"tolerations,omitempty"`
+	Affinity           map[string]interface{} `json:"affinity,omitempty"`
+	Volumes            []Volume               `json:"volumes,omitempty"`
+	ImagePullSecrets   []LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	SecurityContext    *PodSecurityContext    `json:"securityContext,omitempty"`
+}
+
+// Container is a Kubernetes container.
+type Container struct {
+	Name            string              `json:"name"`
+	Image           string              `json:"image"`
+	ImagePullPolicy string              `json:"imagePullPolicy,omitempty"`
+	Command         []string            `json:"command,omitempty"`
+	Args            []string            `json:"args,omitempty"`
+	Env             []EnvVar            `json:"env,omitempty"`
+	Ports           []ContainerPort     `json:"ports,omitempty"`
+	Resources       *ContainerResources `json:"resources,omitempty"`
+	VolumeMounts    []VolumeMount       `json:"volumeMounts,omitempty"`
+	SecurityContext *SecurityContext    `json:"securityContext,omitempty"`
+}
+
+// EnvVar is a Kubernetes container environment variable.
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ContainerPort is a Kubernetes container port.
+type ContainerPort struct {
+	ContainerPort int `json:"containerPort"`
+}
+
+// VolumeMount is a Kubernetes container volume mount.
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+	SubPath   string `json:"subPath,omitempty"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+}
+
+// LocalObjectReference references a Kubernetes object in the same namespace.
+type LocalObjectReference struct {
+	Name string `json:"name"`
+}
+
+// Volume is a Kubernetes pod volume.
+type Volume struct {
+	Name      string                 `json:"name"`
+	Secret    *SecretVolumeSource    `json:"secret,omitempty"`
+	ConfigMap *ConfigMapVolumeSource `json:"configMap,omitempty"`
+	EmptyDir  *EmptyDirVolumeSource  `json:"emptyDir,omitempty"`
+}
+
+// SecretVolumeSource is a Kubernetes volume of a Secret.
+type SecretVolumeSource struct {
+	SecretName string      `json:"secretName"`
+	Items      []KeyToPath `json:"items,omitempty"`
+}
+
+// ConfigMapVolumeSource is a Kubernetes volume of a ConfigMap.
+type ConfigMapVolumeSource struct {
+	Name  string      `json:"name"`
+	Items []KeyToPath `json:"items,omitempty"`
+}
+
+// EmptyDirVolumeSource is a Kubernetes volume of an empty directory sharing
+// the lifetime of its pod.
+type EmptyDirVolumeSource struct{}
+
+// KeyToPath projects a key of a Kubernetes Secret or ConfigMap volume to a
+// file path.
+type KeyToPath struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+}
+
+// PodSecurityContext is a Kubernetes pod security context.
+type PodSecurityContext struct {
+	FSGroup *int64 `json:"fsGroup,omitempty"`
+}
+
+// SecurityContext is a Kubernetes container security context.
+type SecurityContext struct {
+	RunAsNonRoot             *bool           `json:"runAsNonRoot,omitempty"`
+	RunAsUser                *int64          `json:"runAsUser,omitempty"`
+	RunAsGroup               *int64          `json:"runAsGroup,omitempty"`
+	AllowPrivilegeEscalation *bool           `json:"allowPrivilegeEscalation,omitempty"`
+	ReadOnlyRootFilesystem   *bool           `json:"readOnlyRootFilesystem,omitempty"`
+	Capabilities             *Capabilities   `json:"capabilities,omitempty"`
+	SeccompProfile           *SeccompProfile `json:"seccompProfile,omitempty"`
+}
+
+// Capabilities are Kubernetes container capabilities.
+type Capabilities struct {
+	Drop []string `json:"drop,omitempty"`
+}
+
+// SeccompProfile is a Kubernetes seccomp profile.
+type SeccompProfile struct {
+	Type string `json:"type"`
+}
+
+// ContainerResources are Kubernetes container resource requests and limits.
+type ContainerResources struct {
+	Requests map[string]string `json:"requests,omitempty"`
+	Limits   map[string]string `json:"limits,omitempty"`
+}
+
+// Toleration is a Kubernetes pod toleration of a node taint.
+type Toleration struct {
+	Key               string `json:"key,omitempty"`
+	Operator          string `json:"operator,omitempty"`
+	Value             string `json:"value,omitempty"`
+	Effect            string `json:"effect,omitempty"`
+	TolerationSeconds *int64 `json:"tolerationSeconds,omitempty"`
+}
+
+// affinityTypes contains the valid Kubernetes affinity types.
+var affinityTypes = []string{"nodeAffinity", "podAffinity", "podAntiAffinity"}
+
+// taintEffects contains the valid Kubernetes taint effects.
+var taintEffects = []string{"NoSchedule", "PreferNoSchedule", "NoExecute"}
+
+// ParseToleration parses a toleration in the key[=value][:effect] format used
+// by kubectl taint. Tolerations without a value use the Exists operator.
+func ParseToleration(s string) (Toleration, error) {
+	var toleration Toleration
+	spec := s
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		toleration.Effect = spec[i+1:]
+		spec = spec[:i]
+	}
+	if i := strings.Index(spec, "="); i >= 0 {
+		toleration.Key = spec[:i]
+		toleration.Value = spec[i+1:]
+		toleration.Operator = "Equal"
+	} else {
+		toleration.Key = spec
+		toleration.Operator = "Exists"
+	}
+	if toleration.Key == "" {
+		return Toleration{}, fmt.Errorf("missing key in toleration %q", s)
+	}
+	if err := toleration.Validate(); err != nil {
+		return Toleration{}, err
+	}
+	return toleration, nil
+}
+
+// Validate ensures a Toleration is well formed.
+func (toleration Toleration) Validate() error {
+	switch toleration.Operator {
+	case "", "Equal":
+	case "Exists":
+		if toleration.Value != "" {
+			return fmt.Errorf("toleration of %q with Exists operator cannot have a value", toleration.Key)
+		}
+	default:
+		return fmt.Errorf("invalid toleration operator %q", toleration.Operator)
+	}
+	if toleration.Effect != "" && !util.ContainsString(taintEffects, toleration.Effect) {
+		return fmt.Errorf("invalid toleration effect %q, want one of %s", toleration.Effect, strings.Join(taintEffects, ", "))
+	}
+	if toleration.TolerationSeconds != nil && toleration.Effect != "NoExecute" {
+		return fmt.Errorf("toleration of %q sets seconds without NoExecute effect", toleration.Key)
+	}
+	return nil
+}
+
+// LoadAffinity loads Kubernetes affinity rules from a YAML or JSON file.
+func LoadAffinity(path string) (map[string]interface{}, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var affinity map[string]interface{}
+	if err := yaml.Unmarshal(buf, &affinity); err != nil {
+		return nil, err
+	}
+	for affinityType := range affinity {
+		if !util.ContainsString(affinityTypes, affinityType) {
+			return nil, fmt.Errorf("invalid affinity type %q, want one of %s", affinityType, strings.Join(affinityTypes, ", "))
+		}
+	}
+	return affinity, nil
+}
+
+// Pod returns the pod manifest of a Kubernetes Box.
+func (box Box) Pod() Pod {
+	volumes, _ := box.objectVolumes()
+	writableVolumes, _ := box.writableVolumes()
+	return Pod{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Metadata: ObjectMeta{
+			Name:      box.Name,
+			Namespace: box.Namespace,
+			Labels: map[string]string{
+				"run":                          box.Name,
+				"app.kubernetes.io/managed-by": "devbox",
+			},
+		},
+		Spec: PodSpec{
+			Containers:         []Container{box.container()},
+			RestartPolicy:      "Always",
+			ServiceAccountName: box.ServiceAccount,
+			NodeSelector:       box.NodeSelector,
+			Tolerations:        box.Tolerations,
+			Affinity:           box.Affinity,
+			Volumes:            append(volumes, writableVolumes...),
+			ImagePullSecrets:   box.imagePullSecrets(),
+			SecurityContext:    box.podSecurityContext(),
+		},
+	}
+}
+
+// container returns the devbox container of a Kubernetes Box.
+func (box Box) container() Container {
+	container := Container{
+		Name:  box.Name,
+		Image: box.imageRef(),
+	}
+	if box.pullAlways {
+		container.ImagePullPolicy = "Always"
+	}
+	if box.KeepAlive != nil {
+		container.Command = box.KeepAlive.Command
+		container.Args = box.KeepAlive.Args
+	}
+	for _, name := range sortedKeys(box.Env) {
+		container.Env = append(container.Env, EnvVar{Name: name, Value: box.Env[name]})
+	}
+	for _, port := range box.Ports {
+		container.Ports = append(container.Ports, ContainerPort{ContainerPort: port})
+	}
+	_, container.VolumeMounts = box.objectVolumes()
+	_, writableMounts := box.writableVolumes()
+	container.VolumeMounts = append(container.VolumeMounts, writableMounts...)
+	container.SecurityContext = box.securityContext()
+	if !box.Resources.Requests.IsEmpty() || !box.Resources.Limits.IsEmpty() {
+		container.Resources = &ContainerResources{
+			Requests: box.Resources.Requests.toMap(),
+			Limits:   box.Resources.Limits.toMap(),
+		}
+	}
+	return container
+}
+
+// PodManifest returns the pod manifest of a Kubernetes Box in YAML format,
+// with any PodPatch applied.
+func (box Box) PodManifest() ([]byte, error) {
+	manifest, err := yaml.Marshal(box.Pod())
+	if err != nil || box.PodPatch == nil {
+		return manifest, err
+	}
+	return box.PodPatch.apply(manifest, box.kubectlArgs)
+}
@@ -0,0 +1,88 @@
+package devbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseToleration(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Toleration
+		wantErr bool
+	}{
+		{
+			name: "test happy path with value and effect",
+			s:    "dedicated=dev:NoSchedule",
+			want: Toleration{Key: "dedicated", Operator: "Equal", Value: "dev", Effect: "NoSchedule"},
+		},
+		{
+			name: "test happy path with key and effect",
+			s:    "dedicated:NoExecute",
+			want: Toleration{Key: "dedicated", Operator: "Exists", Effect: "NoExecute"},
+		},
+		{
+			name: "test happy path with key only",
+			s:    "dedicated",
+			want: Toleration{Key: "dedicated", Operator: "Exists"},
+		},
+		{
+			name:    "test crappy effect",
+			s:       "dedicated=dev:NoWay",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy key",
+			s:       "=dev:NoSchedule",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseToleration(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseToleration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseToleration() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_Pod(t *testing.T) {
+	box := Box{
+		Image:          "example.com/image:1.0.0",
+		Name:           "eks",
+		Namespace:      "devbox",
+		Resources:      Resources{Limits: ResourceList{Memory: "4Gi"}},
+		NodeSelector:   map[string]string{"pool": "dev"},
+		Tolerations:    []Toleration{{Key: "dedicated", Operator: "Exists", Effect: "NoSchedule"}},
+		ServiceAccount: "devbox",
+	}
+	pod := box.Pod()
+	if pod.Metadata.Name != "eks" || pod.Metadata.Namespace != "devbox" {
+		t.Errorf("Pod() metadata = %v, want eks in devbox namespace", pod.Metadata)
+	}
+	if len(pod.Spec.Containers) != 1 {
+		t.Fatalf("Pod() containers = %d, want 1", len(pod.Spec.Containers))
+	}
+	container := pod.Spec.Containers[0]
+	if container.Image != box.Image {
+		t.Errorf("Pod() image = %s, want %s", container.Image, box.Image)
+	}
+	if container.Resources == nil || container.Resources.Limits["memory"] != "4Gi" {
+		t.Errorf("Pod() resources = %v, want 4Gi memory limit", container.Resources)
+	}
+	if pod.Spec.ServiceAccountName != "devbox" {
+		t.Errorf("Pod() service account = %s, want devbox", pod.Spec.ServiceAccountName)
+	}
+	if !reflect.DeepEqual(pod.Spec.NodeSelector, box.NodeSelector) {
+		t.Errorf("Pod() node selector = %v, want %v", pod.Spec.NodeSelector, box.NodeSelector)
+	}
+	if !reflect.DeepEqual(pod.Spec.Tolerations, box.Tolerations) {
+		t.Errorf("Pod() tolerations = %v, want %v", pod.Spec.Tolerations, box.Tolerations)
+	}
+}
@@ -0,0 +1,239 @@
+package devbox
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// dockerHubHost is the registry host of images without one.
+const dockerHubHost = "docker.io"
+
+// dockerHubAuthKey is the key of Docker Hub credentials in docker config
+// files.
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// invalidSecretNameChars matches characters not allowed in Secret names.
+var invalidSecretNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// dockerConfig is a docker config file, as written by docker login.
+type dockerConfig struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredsStore  string                `json:"credsStore"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+}
+
+// dockerAuth is the credentials of a registry in a docker config file.
+type dockerAuth struct {
+	Auth     string `json:"auth,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// RegistryHost returns the registry host of an image, as determined by docker.
+// Images without a host, whose first path component is neither localhost nor
+// contains a dot or port, are Docker Hub images.
+func RegistryHost(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return dockerHubHost
+	}
+	host := image[:i]
+	if host != "localhost" && !strings.ContainsAny(host, ".:") {
+		return dockerHubHost
+	}
+	if host == "index.docker.io" || host == "registry-1.docker.io" {
+		return dockerHubHost
+	}
+	return host
+}
+
+// RegistrySecretName returns the default name of the image pull secret of a
+// registry host.
+func RegistrySecretName(host string) string {
+	name := invalidSecretNameChars.ReplaceAllString(strings.ToLower(host), "-")
+	return fmt.Sprintf("devbox-registry-%s", strings.Trim(name, "-."))
+}
+
+// DefaultDockerConfig returns the path of the docker config file, honoring
+// the DOCKER_CONFIG environment variable.
+func DefaultDockerConfig() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	return filepath.Join("~", ".docker", "config.json")
+}
+
+// authHost returns the registry host of a docker config credentials key,
+// which may be a URL.
+func authHost(key string) string {
+	if key == dockerHubAuthKey {
+		return dockerHubHost
+	}
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	return RegistryHost(strings.SplitN(key, "/", 2)[0] + "/")
+}
+
+// loadRegistryAuth returns the credentials of a registry host from a docker
+// config file, using its credential helpers when configured.
+func loadRegistryAuth(path string, host string) (dockerAuth, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return dockerAuth{}, err
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return dockerAuth{}, err
+	}
+	var cfg dockerConfig
+	if err := json.Unmarshal(buf, &cfg); err != nil {
+		return dockerAuth{}, fmt.Errorf("cannot parse %s: %v", path, err)
+	}
+
+	key := host
+	if host == dockerHubHost {
+		key = dockerHubAuthKey
+	}
+	for helperHost, helper := range cfg.CredHelpers {
+		if authHost(helperHost) == host {
+			return credentialHelperAuth(helper, helperHost)
+		}
+	}
+	for authKey, auth := range cfg.Auths {
+		if authHost(authKey) != host {
+			continue
+		}
+		if auth.Auth != "" {
+			return decodeDockerAuth(auth.Auth)
+		}
+		if auth.Username != "" {
+			return auth, nil
+		}
+		key = authKey
+	}
+	if cfg.CredsStore != "" {
+		return credentialHelperAuth(cfg.CredsStore, key)
+	}
+	return dockerAuth{}, fmt.Errorf("no credentials for registry %s in %s, log in with docker login first", host, path)
+}
+
+// decodeDockerAuth decodes the base64 encoded username:password credentials
+// of a docker config file.
+func decodeDockerAuth(auth string) (dockerAuth, error) {
+	buf, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return dockerAuth{}, fmt.Errorf("invalid registry credentials: %v", err)
+	}
+	parts := strings.SplitN(string(buf), ":", 2)
+	if len(parts) != 2 {
+		return dockerAuth{}, errors.New("invalid registry credentials, want username:password")
+	}
+	return dockerAuth{Username: parts[0], Password: parts[1]}, nil
+}
+
+// credentialHelperAuth returns the credentials of a registry from a docker
+// credential helper.
+func credentialHelperAuth(helper string, key string) (dockerAuth, error) {
+	name := fmt.Sprintf("docker-credential-%s", helper)
+	if config.Verbose {
+		fmt.Printf("cmd: %s get\n", name)
+	}
+	cmd := exec.Command(name, "get")
+	cmd.Stdin = strings.NewReader(key)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return dockerAuth{}, fmt.Errorf("cannot get credentials of %s from %s: %v", key, name, err)
+	}
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return dockerAuth{}, fmt.Errorf("cannot parse credentials from %s: %v", name, err)
+	}
+	// Identity tokens are exchanged for access tokens by docker, which
+	// kubelet cannot do.
+	if creds.Username == "<token>" {
+		return dockerAuth{}, fmt.Errorf("identity token credentials of %s cannot be used by Kubernetes", key)
+	}
+	return dockerAuth{Username: creds.Username, Password: creds.Secret}, nil
+}
+
+// registrySecretManifest returns the manifest of a Kubernetes docker registry
+// Secret with the credentials of a registry host.
+func registrySecretManifest(name string, namespace string, host string, auth dockerAuth) ([]byte, error) {
+	key := host
+	if host == dockerHubHost {
+		key = dockerHubAuthKey
+	}
+	auth.Auth = base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+	dockerConfigJSON, err := json.Marshal(dockerConfig{Auths: map[string]dockerAuth{key: auth}})
+	if err != nil {
+		return nil, err
+	}
+	secret := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "devbox"},
+		},
+		"type": "kubernetes.io/dockerconfigjson",
+		"data": map[string]string{
+			".dockerconfigjson": base64.StdEncoding.EncodeToString(dockerConfigJSON),
+		},
+	}
+	return yaml.Marshal(secret)
+}
+
+// RegistryLogin creates or updates a docker registry Secret in the namespace
+// of a Kubernetes Box with the credentials of a registry host from a docker
+// config file, and returns the updated Box pulling its image with it.
+func (box Box) RegistryLogin(host string, secretName string, dockerConfigPath string) (Box, error) {
+	if box.Namespace == "" {
+		return box, errors.New("registry login is only supported for Kubernetes devboxes, use docker login instead")
+	}
+	auth, err := loadRegistryAuth(dockerConfigPath, host)
+	if err != nil {
+		return box, err
+	}
+	manifest, err := registrySecretManifest(secretName, box.Namespace, host, auth)
+	if err != nil {
+		return box, err
+	}
+	if config.Verbose {
+		fmt.Printf("msg: creating image pull secret %s for registry %s in %s namespace\n", secretName, host, box.Namespace)
+	}
+	if err := util.ExecCommandWithInput(manifest, "kubectl", box.kubectlArgs("apply", "-f", "-")...); err != nil {
+		return box, err
+	}
+	if !util.ContainsString(box.ImagePullSecrets, secretName) {
+		box.ImagePullSecrets = append(box.ImagePullSecrets, secretName)
+	}
+	return box, nil
+}
+
+// imagePullSecrets returns the image pull secret references of a Kubernetes
+// Box.
+func (box Box) imagePullSecrets() []LocalObjectReference {
+	var refs []LocalObjectReference
+	for _, name := range box.ImagePullSecrets {
+		refs = append(refs, LocalObjectReference{Name: name})
+	}
+	return refs
+}
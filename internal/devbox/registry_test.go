@@ -0,0 +1,107 @@
+package devbox
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistryHost(t *testing.T) {
+	tests := []struct {
+		name  string
+		image string
+		want  string
+	}{
+		{
+			name:  "test happy path",
+			image: "ghcr.io/mojochao/devbox:latest",
+			want:  "ghcr.io",
+		},
+		{
+			name:  "test happy path official image",
+			image: "ubuntu:22.04",
+			want:  "docker.io",
+		},
+		{
+			name:  "test happy path user image",
+			image: "mojochao/devbox-base@sha256:0123",
+			want:  "docker.io",
+		},
+		{
+			name:  "test happy path docker hub host",
+			image: "index.docker.io/library/ubuntu",
+			want:  "docker.io",
+		},
+		{
+			name:  "test happy path registry port",
+			image: "localhost:5000/devbox",
+			want:  "localhost:5000",
+		},
+		{
+			name:  "test happy path localhost",
+			image: "localhost/devbox",
+			want:  "localhost",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RegistryHost(tt.image); got != tt.want {
+				t.Errorf("RegistryHost() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistrySecretName(t *testing.T) {
+	if got := RegistrySecretName("localhost:5000"); got != "devbox-registry-localhost-5000" {
+		t.Errorf("RegistrySecretName() got = %v, want devbox-registry-localhost-5000", got)
+	}
+}
+
+func Test_loadRegistryAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devbox-docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	auth := base64.StdEncoding.EncodeToString([]byte("dev:secret"))
+	cfg := `{"auths": {"https://index.docker.io/v1/": {"auth": "` + auth + `"}, "registry.example.com": {"auth": "` + auth + `"}}}`
+	if err := ioutil.WriteFile(path, []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		host    string
+		wantErr bool
+	}{
+		{
+			name: "test happy path",
+			host: "registry.example.com",
+		},
+		{
+			name: "test happy path docker hub",
+			host: "docker.io",
+		},
+		{
+			name:    "test crappy unknown registry",
+			host:    "ghcr.io",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadRegistryAuth(path, tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadRegistryAuth() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && (got.Username != "dev" || got.Password != "secret") {
+				t.Errorf("loadRegistryAuth() got = %v, want dev:secret", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package devbox
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// Resources contains compute resource requests and limits of a Box.
+type Resources struct {
+	// Requests are the minimum resources reserved for the devbox.
+	Requests ResourceList `yaml:"requests"`
+
+	// Limits are the maximum resources usable by the devbox.
+	Limits ResourceList `yaml:"limits"`
+
+	// Ulimits are process limits in docker --ulimit format, for example
+	// "nofile=90000:90000" (Docker devboxes only). DefaultUlimits are applied
+	// if none are set, unless Ulimits contains only UlimitsNone.
+	Ulimits []string `yaml:"ulimits"`
+}
+
+// ResourceList contains resource quantities in Kubernetes quantity format,
+// for example "500m" CPU or "2Gi" memory.
+type ResourceList struct {
+	// CPU is the CPU quantity.
+	CPU string `yaml:"cpu"`
+
+	// Memory is the memory quantity.
+	Memory string `yaml:"memory"`
+
+	// EphemeralStorage is the ephemeral storage quantity.
+	EphemeralStorage string `yaml:"ephemeralStorage"`
+}
+
+// DefaultUlimits contains the ulimits applied to Docker devboxes without any.
+var DefaultUlimits = []string{"nofile=90000:90000"}
+
+// UlimitsNone is the sole ulimit of Docker devboxes opting out of
+// DefaultUlimits, keeping the ulimits of the docker daemon.
+const UlimitsNone = "none"
+
+// ulimitNames contains the names of ulimits supported by docker.
+var ulimitNames = []string{
+	"core", "cpu", "data", "fsize", "locks", "memlock", "msgqueue", "nice",
+	"nofile", "nproc", "rss", "rtprio", "rttime", "sigpending", "stack",
+}
+
+// quantitySuffixes contains the multipliers of Kubernetes quantity suffixes.
+// Binary suffixes are listed first so that "Mi" is matched before "M".
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"Ti", 1 << 40},
+	{"Pi", 1 << 50},
+	{"Ei", 1 << 60},
+	{"n", 1e-9},
+	{"u", 1e-6},
+	{"m", 1e-3},
+	{"k", 1e3},
+	{"M", 1e6},
+	{"G", 1e9},
+	{"T", 1e12},
+	{"P", 1e15},
+	{"E", 1e18},
+}
+
+// IsEmpty tests if no resources are set.
+func (resources Resources) IsEmpty() bool {
+	return resources.Requests.IsEmpty() && resources.Limits.IsEmpty() && len(resources.Ulimits) == 0
+}
+
+// Validate ensures all quantities and ulimits are well formed and that no
+// request exceeds its limit.
+func (resources Resources) Validate() error {
+	if err := resources.Requests.validate("request"); err != nil {
+		return err
+	}
+	if err := resources.Limits.validate("limit"); err != nil {
+		return err
+	}
+	checks := []struct {
+		name    string
+		request string
+		limit   string
+	}{
+		{"cpu", resources.Requests.CPU, resources.Limits.CPU},
+		{"memory", resources.Requests.Memory, resources.Limits.Memory},
+		{"ephemeral-storage", resources.Requests.EphemeralStorage, resources.Limits.EphemeralStorage},
+	}
+	for _, check := range checks {
+		if check.request == "" || check.limit == "" {
+			continue
+		}
+		request, _ := parseQuantity(check.request)
+		limit, _ := parseQuantity(check.limit)
+		if request > limit {
+			return fmt.Errorf("%s request %s exceeds limit %s", check.name, check.request, check.limit)
+		}
+	}
+	if util.ContainsString(resources.Ulimits, UlimitsNone) {
+		if len(resources.Ulimits) > 1 {
+			return fmt.Errorf("ulimit %s cannot be combined with other ulimits", UlimitsNone)
+		}
+		return nil
+	}
+	for _, ulimit := range resources.Ulimits {
+		if err := validateUlimit(ulimit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ulimits returns the ulimits applied to a Docker devbox, which are
+// DefaultUlimits if none are set, so that devboxes saved before they existed
+// get them too.
+func (resources Resources) ulimits() []string {
+	switch {
+	case len(resources.Ulimits) == 0:
+		return DefaultUlimits
+	case len(resources.Ulimits) == 1 && resources.Ulimits[0] == UlimitsNone:
+		return nil
+	}
+	return resources.Ulimits
+}
+
+// IsEmpty tests if no quantities are set.
+func (list ResourceList) IsEmpty() bool {
+	return list.CPU == "" && list.Memory == "" && list.EphemeralStorage == ""
+}
+
+// toMap returns the quantities keyed by their Kubernetes resource names.
+func (list ResourceList) toMap() map[string]string {
+	m := make(map[string]string)
+	if list.CPU != "" {
+		m["cpu"] = list.CPU
+	}
+	if list.Memory != "" {
+		m["memory"] = list.Memory
+	}
+	if list.EphemeralStorage != "" {
+		m["ephemeral-storage"] = list.EphemeralStorage
+	}
+	return m
+}
+
+func (list ResourceList) validate(kind string) error {
+	for name, quantity := range list.toMap() {
+		if _, err := parseQuantity(quantity); err != nil {
+			return fmt.Errorf("invalid %s %s: %v", name, kind, err)
+		}
+	}
+	return nil
+}
+
+// dockerArgs returns the docker run arguments applying resources. Ephemeral
+// storage limits are applied with --storage-opt, which docker only supports
+// with the overlay2 storage driver on xfs mounted with pquota, or with the
+// btrfs, zfs and devicemapper storage drivers.
+func (resources Resources) dockerArgs() []string {
+	var args []string
+	if resources.Limits.CPU != "" {
+		cpus, _ := parseQuantity(resources.Limits.CPU)
+		args = append(args, fmt.Sprintf("--cpus=%s", strconv.FormatFloat(cpus, 'f', -1, 64)))
+	}
+	if resources.Requests.CPU != "" {
+		cpus, _ := parseQuantity(resources.Requests.CPU)
+		shares := int64(cpus * 1024)
+		if shares < 2 {
+			shares = 2
+		}
+		args = append(args, fmt.Sprintf("--cpu-shares=%d", shares))
+	}
+	if resources.Limits.Memory != "" {
+		bytes, _ := parseQuantity(resources.Limits.Memory)
+		args = append(args, fmt.Sprintf("--memory=%d", int64(bytes)))
+	}
+	if resources.Requests.Memory != "" {
+		bytes, _ := parseQuantity(resources.Requests.Memory)
+		args = append(args, fmt.Sprintf("--memory-reservation=%d", int64(bytes)))
+	}
+	if resources.Limits.EphemeralStorage != "" {
+		bytes, _ := parseQuantity(resources.Limits.EphemeralStorage)
+		args = append(args, fmt.Sprintf("--storage-opt=size=%d", int64(bytes)))
+	}
+	for _, ulimit := range resources.ulimits() {
+		args = append(args, fmt.Sprintf("--ulimit=%s", ulimit))
+	}
+	return args
+}
+
+// parseQuantity parses a Kubernetes resource quantity into its value in base
+// units, for example "500m" is 0.5 and "1Ki" is 1024.
+func parseQuantity(s string) (float64, error) {
+	number, multiplier := s, 1.0
+	for _, suffix := range quantitySuffixes {
+		if strings.HasSuffix(s, suffix.suffix) {
+			number = strings.TrimSuffix(s, suffix.suffix)
+			multiplier = suffix.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("malformed quantity %q", s)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("negative quantity %q", s)
+	}
+	return value * multiplier, nil
+}
+
+// validateUlimit ensures a ulimit is in name=soft[:hard] format.
+func validateUlimit(ulimit string) error {
+	parts := strings.SplitN(ulimit, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("malformed ulimit %q, want name=soft[:hard]", ulimit)
+	}
+	if !util.ContainsString(ulimitNames, parts[0]) {
+		return fmt.Errorf("unknown ulimit %q", parts[0])
+	}
+	values := strings.Split(parts[1], ":")
+	if len(values) > 2 {
+		return fmt.Errorf("malformed ulimit %q, want name=soft[:hard]", ulimit)
+	}
+	var limits []int64
+	for _, value := range values {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed ulimit %q: %v", ulimit, err)
+		}
+		limits = append(limits, limit)
+	}
+	if len(limits) == 2 && limits[1] != -1 && limits[0] > limits[1] {
+		return fmt.Errorf("ulimit %q soft limit exceeds hard limit", ulimit)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package devbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseQuantity(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    float64
+		wantErr bool
+	}{
+		{name: "test cores", s: "2", want: 2},
+		{name: "test millicores", s: "500m", want: 0.5},
+		{name: "test binary suffix", s: "512Mi", want: 512 * 1024 * 1024},
+		{name: "test decimal suffix", s: "1G", want: 1e9},
+		{name: "test exponent", s: "1e3", want: 1000},
+		{name: "test crappy suffix", s: "1Gb", wantErr: true},
+		{name: "test crappy number", s: "lots", wantErr: true},
+		{name: "test negative", s: "-1", wantErr: true},
+		{name: "test empty", s: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseQuantity(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseQuantity() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseQuantity() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResources_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources Resources
+		wantErr   bool
+	}{
+		{
+			name:      "test empty",
+			resources: Resources{},
+		},
+		{
+			name: "test happy path",
+			resources: Resources{
+				Requests: ResourceList{CPU: "500m", Memory: "1Gi"},
+				Limits:   ResourceList{CPU: "2", Memory: "4Gi", EphemeralStorage: "10Gi"},
+				Ulimits:  []string{"nofile=90000:90000", "nproc=4096"},
+			},
+		},
+		{
+			name: "test request exceeds limit",
+			resources: Resources{
+				Requests: ResourceList{Memory: "8Gi"},
+				Limits:   ResourceList{Memory: "4Gi"},
+			},
+			wantErr: true,
+		},
+		{
+			name:      "test malformed quantity",
+			resources: Resources{Limits: ResourceList{CPU: "two"}},
+			wantErr:   true,
+		},
+		{
+			name:      "test unknown ulimit",
+			resources: Resources{Ulimits: []string{"files=10"}},
+			wantErr:   true,
+		},
+		{
+			name:      "test soft ulimit exceeds hard",
+			resources: Resources{Ulimits: []string{"nofile=20:10"}},
+			wantErr:   true,
+		},
+		{
+			name:      "test no ulimits",
+			resources: Resources{Ulimits: []string{UlimitsNone}},
+		},
+		{
+			name:      "test no ulimits with other ulimits",
+			resources: Resources{Ulimits: []string{UlimitsNone, "nproc=4096"}},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.resources.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResources_dockerArgs(t *testing.T) {
+	resources := Resources{
+		Requests: ResourceList{CPU: "500m", Memory: "1Ki"},
+		Limits:   ResourceList{CPU: "1500m", Memory: "2Ki", EphemeralStorage: "1k"},
+		Ulimits:  []string{"nofile=90000:90000"},
+	}
+	want := []string{
+		"--cpus=1.5",
+		"--cpu-shares=512",
+		"--memory=2048",
+		"--memory-reservation=1024",
+		"--storage-opt=size=1000",
+		"--ulimit=nofile=90000:90000",
+	}
+	if got := resources.dockerArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("dockerArgs() got = %v, want %v", got, want)
+	}
+}
+
+func TestResources_ulimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		ulimits []string
+		want    []string
+	}{
+		{
+			name:    "test happy path",
+			ulimits: []string{"nproc=4096"},
+			want:    []string{"nproc=4096"},
+		},
+		{
+			name: "test happy path default ulimits",
+			want: DefaultUlimits,
+		},
+		{
+			name:    "test happy path no ulimits",
+			ulimits: []string{UlimitsNone},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Resources{Ulimits: tt.ulimits}).ulimits(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ulimits() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package devbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// Runtime contains information recorded on the use of a Box.
+type Runtime struct {
+	// StartedAt is when the devbox was last started.
+	StartedAt *time.Time `yaml:"startedAt"`
+
+	// StoppedAt is when the devbox was last stopped.
+	StoppedAt *time.Time `yaml:"stoppedAt"`
+
+	// SetupTypes are the manifest types of the last setup of the devbox.
+	SetupTypes []ManifestType `yaml:"setupTypes"`
+
+	// ImageDigest is the digest of the image the devbox was last started
+	// with, if known.
+	ImageDigest string `yaml:"imageDigest"`
+
+	// BuildHash is the hash of the build inputs the devbox image was last
+	// built from, if built by devbox.
+	BuildHash string `yaml:"buildHash"`
+
+	// ObjectID is the ID of the Docker container or the UID of the Kubernetes
+	// pod the devbox was last started in.
+	ObjectID string `yaml:"objectID"`
+}
+
+// Started records the start of a Box in its Runtime, along with the ID of the
+// container or pod it runs in and the digest of its image, and returns the
+// updated Box. The start is recorded even if the container or pod cannot be
+// inspected, in which case an error is also returned.
+func (box Box) Started(at time.Time) (Box, error) {
+	box.Runtime.StartedAt = &at
+	box.Runtime.ImageDigest = ""
+	box.Runtime.ObjectID = ""
+	var err error
+	if box.Namespace == "" {
+		box.Runtime.ObjectID, box.Runtime.ImageDigest, err = box.inspectContainer()
+	} else {
+		box.Runtime.ObjectID, box.Runtime.ImageDigest, err = box.inspectPod()
+	}
+	if err != nil {
+		return box, fmt.Errorf("cannot inspect devbox %s: %v", box.Name, err)
+	}
+	return box, nil
+}
+
+// Stopped records the stop of a Box in its Runtime and returns the updated
+// Box.
+func (box Box) Stopped(at time.Time) Box {
+	box.Runtime.StoppedAt = &at
+	return box
+}
+
+// inspectContainer returns the ID of the Docker container of a Box and the
+// digest of its image. The repository digest of the image is preferred, as
+// images built locally have none, their ID is used otherwise.
+func (box Box) inspectContainer() (string, string, error) {
+	out, err := util.OutputCommand("docker", "inspect", "--format", "{{.Id}} {{.Image}}", box.Name)
+	if err != nil {
+		return "", "", err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("unexpected docker inspect output %q", out)
+	}
+	id, digest := fields[0], fields[1]
+	out, err = util.OutputCommand("docker", "image", "inspect", "--format", "{{range .RepoDigests}}{{println .}}{{end}}", digest)
+	if err == nil {
+		if repoDigests := strings.Fields(string(out)); len(repoDigests) > 0 {
+			digest = repoDigests[0]
+		}
+	}
+	return id, digest, nil
+}
+
+// podRuntime is the subset of a Kubernetes pod used to record the Runtime of
+// a Box.
+type podRuntime struct {
+	Metadata struct {
+		UID string `json:"uid"`
+	} `json:"metadata"`
+	Status struct {
+		ContainerStatuses          []containerImage `json:"containerStatuses"`
+		EphemeralContainerStatuses []containerImage `json:"ephemeralContainerStatuses"`
+	} `json:"status"`
+}
+
+// containerImage is the subset of a Kubernetes container status identifying
+// its image.
+type containerImage struct {
+	Name    string `json:"name"`
+	ImageID string `json:"imageID"`
+}
+
+// inspectPod returns the UID of the Kubernetes pod of a Box and the digest of
+// the image of its container, which is only known once it is running.
+func (box Box) inspectPod() (string, string, error) {
+	out, err := util.OutputCommand("kubectl", box.kubectlArgs("get", "pod", box.podName(), "-o", "json")...)
+	if err != nil {
+		return "", "", err
+	}
+	var pod podRuntime
+	if err := json.Unmarshal(out, &pod); err != nil {
+		return "", "", err
+	}
+	statuses := append(pod.Status.ContainerStatuses, pod.Status.EphemeralContainerStatuses...)
+	for _, status := range statuses {
+		if status.Name == box.Name {
+			return pod.Metadata.UID, trimImageIDScheme(status.ImageID), nil
+		}
+	}
+	return pod.Metadata.UID, "", nil
+}
+
+// trimImageIDScheme trims the scheme some container runtimes prefix Kubernetes
+// container image IDs with, such as docker-pullable://.
+func trimImageIDScheme(imageID string) string {
+	if i := strings.Index(imageID, "://"); i >= 0 {
+		return imageID[i+3:]
+	}
+	return imageID
+}
@@ -0,0 +1,32 @@
+package devbox
+
+import "testing"
+
+func Test_trimImageIDScheme(t *testing.T) {
+	tests := []struct {
+		name    string
+		imageID string
+		want    string
+	}{
+		{
+			name:    "test docker-pullable image id",
+			imageID: "docker-pullable://ubuntu@sha256:0123abcd",
+			want:    "ubuntu@sha256:0123abcd",
+		},
+		{
+			name:    "test image id without scheme",
+			imageID: "docker.io/library/ubuntu@sha256:0123abcd",
+			want:    "docker.io/library/ubuntu@sha256:0123abcd",
+		},
+		{
+			name: "test empty image id",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimImageIDScheme(tt.imageID); got != tt.want {
+				t.Errorf("trimImageIDScheme() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
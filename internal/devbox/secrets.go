@@ -0,0 +1,187 @@
+package devbox
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SecretPolicy determines how files containing secrets are handled when
+// copied to a Box by setup.
+type SecretPolicy = string
+
+const (
+	// SecretPolicyBlock skips files containing secrets.
+	SecretPolicyBlock SecretPolicy = "block"
+
+	// SecretPolicyWarn copies files containing credentials and tokens with a
+	// warning, and skips files containing private keys, which are used with
+	// a forwarded SSH agent instead.
+	SecretPolicyWarn SecretPolicy = "warn"
+
+	// SecretPolicyAllow copies files without detecting secrets.
+	SecretPolicyAllow SecretPolicy = "allow"
+)
+
+// SecretPolicies contains the supported secret policies.
+var SecretPolicies = []string{SecretPolicyBlock, SecretPolicyWarn, SecretPolicyAllow}
+
+// maxSecretScanSize is the number of bytes at the start of a file in which
+// secrets are detected.
+const maxSecretScanSize = 1024 * 1024
+
+// secretDetector detects a kind of secret in files by their paths or
+// contents.
+type secretDetector struct {
+	// kind describes the kind of secret detected.
+	kind string
+
+	// paths contains the slash separated path suffixes of files always
+	// containing the secret.
+	paths []string
+
+	// content matches the secret in file contents, if set.
+	content *regexp.Regexp
+}
+
+// privateKeySecret is the kind of private key secrets.
+const privateKeySecret = "private key"
+
+// secretDetectors contains the detectors of secrets in files, in order of
+// precedence.
+var secretDetectors = []secretDetector{
+	{
+		// OpenSSH, PEM encoded and PuTTY keys, whatever their names.
+		kind:    privateKeySecret,
+		content: regexp.MustCompile(`PRIVATE KEY-----|PuTTY-User-Key-File`),
+	},
+	{
+		kind: "cloud credentials",
+		paths: []string{
+			".aws/credentials",
+			".config/gcloud/credentials.db",
+			".config/gcloud/access_tokens.db",
+			".config/gcloud/application_default_credentials.json",
+			".azure/accessTokens.json",
+			".azure/msal_token_cache.json",
+			".azure/msal_token_cache.bin",
+		},
+		content: regexp.MustCompile(`(?i)aws_secret_access_key|aws_session_token|"private_key_id"|\bA(KIA|SIA)[0-9A-Z]{16}\b`),
+	},
+	{
+		kind:  "token",
+		paths: []string{".netrc", ".git-credentials", ".docker/config.json", ".pypirc"},
+		content: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36}\b|\bgithub_pat_[A-Za-z0-9_]{22,}|\bglpat-[A-Za-z0-9_-]{20,}|` +
+			`\bxox[abposr]-[A-Za-z0-9-]{10,}|_authToken\s*=|\bnpm_[A-Za-z0-9]{36}\b`),
+	},
+}
+
+// detectSecret returns the kind of secret a file contains, or an empty string
+// if none is detected.
+func detectSecret(path string) (string, error) {
+	slashPath := filepath.ToSlash(path)
+	for _, detector := range secretDetectors {
+		for _, suffix := range detector.paths {
+			if strings.HasSuffix(slashPath, "/"+suffix) {
+				return detector.kind, nil
+			}
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	head := make([]byte, maxSecretScanSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	for _, detector := range secretDetectors {
+		if detector.content != nil && detector.content.Match(head[:n]) {
+			return detector.kind, nil
+		}
+	}
+	return "", nil
+}
+
+// blocksSecret tests if a policy blocks copying a file containing a kind of
+// secret.
+func blocksSecret(policy SecretPolicy, kind string) bool {
+	switch policy {
+	case SecretPolicyAllow:
+		return false
+	case SecretPolicyWarn:
+		return kind == privateKeySecret
+	}
+	return true
+}
+
+// findSecrets detects secrets in a file, or the files of a directory, and
+// returns the kinds of secrets by the paths of the files containing them.
+func findSecrets(path string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Links are resolved like when staged, skipping those to directories.
+		if info.Mode()&os.ModeSymlink != 0 {
+			if info, err = os.Stat(path); err != nil || info.IsDir() {
+				return nil
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		kind, err := detectSecret(path)
+		if err != nil || kind == "" {
+			return err
+		}
+		secrets[path] = kind
+		return nil
+	})
+	return secrets, err
+}
+
+// screenSecrets detects secrets in a file, or the files of a directory, and
+// returns the paths of those blocked by policy. Blocked files are logged as
+// skipped, and other files containing secrets are logged as warnings.
+func screenSecrets(path string, policy SecretPolicy) (map[string]bool, error) {
+	secrets, err := findSecrets(path)
+	if err != nil {
+		return nil, err
+	}
+	blocked := make(map[string]bool)
+	for _, path := range sortedKeys(secrets) {
+		kind := secrets[path]
+		if blocksSecret(policy, kind) {
+			fmt.Printf("skipping %s, detected %s\n", path, kind)
+			blocked[path] = true
+		} else {
+			fmt.Printf("warning: copying %s, detected %s\n", path, kind)
+		}
+	}
+	return blocked, nil
+}
+
+// setupSecretPolicy returns the SecretPolicy of the setup of a Box, which is
+// that of opts, if set, or of the Box. By default, secrets are blocked in
+// Kubernetes devboxes, whose pods may be accessible by other cluster users,
+// and warned of in Docker devboxes.
+func (box Box) setupSecretPolicy(opts SetupOptions) SecretPolicy {
+	if opts.SecretPolicy != "" {
+		return opts.SecretPolicy
+	}
+	if box.SecretPolicy != "" {
+		return box.SecretPolicy
+	}
+	if box.Namespace != "" {
+		return SecretPolicyBlock
+	}
+	return SecretPolicyWarn
+}
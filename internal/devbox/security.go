@@ -0,0 +1,162 @@
+package devbox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// SecurityProfile identifies the Pod Security Standard a Kubernetes Box pod
+// complies with.
+type SecurityProfile = string
+
+const (
+	// SecurityRestricted complies with the restricted Pod Security Standard,
+	// running as a non-root user without capabilities or privilege escalation,
+	// with the RuntimeDefault seccomp profile and a read-only root filesystem.
+	SecurityRestricted SecurityProfile = "restricted"
+
+	// SecurityBaseline complies with the baseline Pod Security Standard, with
+	// the RuntimeDefault seccomp profile and without privilege escalation.
+	SecurityBaseline SecurityProfile = "baseline"
+
+	// SecurityPrivileged applies no restrictions, which is the default.
+	SecurityPrivileged SecurityProfile = "privileged"
+)
+
+// SecurityProfiles contains the supported security profiles.
+var SecurityProfiles = []string{SecurityRestricted, SecurityBaseline, SecurityPrivileged}
+
+// RunAs contains the numeric user and group IDs a Kubernetes Box runs as,
+// which the kubelet requires to verify that a pod does not run as root.
+type RunAs struct {
+	// User is the user ID.
+	User int64 `yaml:"user"`
+
+	// Group is the group ID.
+	Group int64 `yaml:"group"`
+}
+
+// ParseRunAs parses a RunAs in UID[:GID] format. The group ID defaults to the
+// user ID.
+func ParseRunAs(s string) (*RunAs, error) {
+	parts := strings.SplitN(s, ":", 2)
+	user, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || user < 0 {
+		return nil, fmt.Errorf("invalid user ID %q", parts[0])
+	}
+	runAs := &RunAs{User: user, Group: user}
+	if len(parts) == 2 {
+		if runAs.Group, err = strconv.ParseInt(parts[1], 10, 64); err != nil || runAs.Group < 0 {
+			return nil, fmt.Errorf("invalid group ID %q", parts[1])
+		}
+	}
+	return runAs, nil
+}
+
+// ResolveRunAs returns a Box running as the numeric user and group IDs of its
+// image, which is verified first so that only the image it runs is inspected.
+// These are the IDs of the image user if numeric and not root, as is usual of
+// distroless images, or else those of the Box user, resolved by running id in
+// a temporary container of the image, which requires sh and id in it.
+func (box Box) ResolveRunAs() (Box, error) {
+	box, err := box.verifyImage()
+	if err != nil {
+		return box, err
+	}
+	image := box.imageRef()
+	if _, err := util.OutputCommand("docker", "pull", image); err != nil {
+		return box, fmt.Errorf("cannot pull image %s: %v", image, err)
+	}
+	out, err := util.OutputCommand("docker", "image", "inspect", "--format", "{{.Config.User}}", image)
+	if err != nil {
+		return box, fmt.Errorf("cannot inspect image %s: %v", image, err)
+	}
+	if runAs := imageRunAs(strings.TrimSpace(string(out))); runAs != nil {
+		box.RunAs = runAs
+		return box, nil
+	}
+
+	// The user is passed as an argument of the script, so that it is never
+	// interpreted by the shell.
+	script := `id -u "$1" && id -g "$1"`
+	out, err = util.OutputCommand("docker", "run", "--rm", "--entrypoint", "sh", image, "-c", script, "sh", box.User)
+	if err != nil {
+		return box, fmt.Errorf("cannot resolve IDs of user %s in image %s, which requires sh and id in the image: %v", box.User, image, err)
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) != 2 {
+		return box, fmt.Errorf("unexpected IDs of user %s in image %s: %q", box.User, image, out)
+	}
+	box.RunAs, err = ParseRunAs(strings.Join(ids, ":"))
+	return box, err
+}
+
+// imageRunAs returns the RunAs of the user of an image in UID[:GID] format,
+// or nil if it is not numeric or is root.
+func imageRunAs(user string) *RunAs {
+	runAs, err := ParseRunAs(user)
+	if err != nil || runAs.User == 0 {
+		return nil
+	}
+	return runAs
+}
+
+// podSecurityContext returns the pod security context of a Kubernetes Box,
+// which sets the group owning its volumes when it runs as a user.
+func (box Box) podSecurityContext() *PodSecurityContext {
+	if box.RunAs == nil {
+		return nil
+	}
+	return &PodSecurityContext{FSGroup: &box.RunAs.Group}
+}
+
+// securityContext returns the container security context of a Kubernetes Box,
+// which complies with its security profile on its own, so that its container
+// also complies when it is a sidecar of a workload.
+func (box Box) securityContext() *SecurityContext {
+	if box.RunAs == nil && (box.SecurityProfile == "" || box.SecurityProfile == SecurityPrivileged) {
+		return nil
+	}
+	context := &SecurityContext{}
+	if box.RunAs != nil {
+		context.RunAsUser = &box.RunAs.User
+		context.RunAsGroup = &box.RunAs.Group
+	}
+	switch box.SecurityProfile {
+	case SecurityRestricted:
+		context.RunAsNonRoot = boolPtr(true)
+		context.ReadOnlyRootFilesystem = boolPtr(true)
+		context.Capabilities = &Capabilities{Drop: []string{"ALL"}}
+		fallthrough
+	case SecurityBaseline:
+		context.AllowPrivilegeEscalation = boolPtr(false)
+		context.SeccompProfile = &SeccompProfile{Type: "RuntimeDefault"}
+	}
+	return context
+}
+
+// writableVolumes returns the pod volumes and container volume mounts of the
+// writable directories of a Kubernetes Box with a read-only root filesystem,
+// which are its temporary and home directories.
+func (box Box) writableVolumes() ([]Volume, []VolumeMount) {
+	if box.SecurityProfile != SecurityRestricted {
+		return nil, nil
+	}
+	volumes := []Volume{
+		{Name: "tmp", EmptyDir: &EmptyDirVolumeSource{}},
+		{Name: "home", EmptyDir: &EmptyDirVolumeSource{}},
+	}
+	volumeMounts := []VolumeMount{
+		{Name: "tmp", MountPath: "/tmp"},
+		{Name: "home", MountPath: box.HomeDir()},
+	}
+	return volumes, volumeMounts
+}
+
+// boolPtr returns a pointer to a bool.
+func boolPtr(b bool) *bool {
+	return &b
+}
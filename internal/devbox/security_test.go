@@ -0,0 +1,146 @@
+package devbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRunAs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    *RunAs
+		wantErr bool
+	}{
+		{
+			name:  "test happy path",
+			input: "1000:2000",
+			want:  &RunAs{User: 1000, Group: 2000},
+		},
+		{
+			name:  "test happy path without group",
+			input: "1000",
+			want:  &RunAs{User: 1000, Group: 1000},
+		},
+		{
+			name:    "test crappy user name",
+			input:   "developer",
+			wantErr: true,
+		},
+		{
+			name:    "test crappy negative group",
+			input:   "1000:-1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRunAs(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRunAs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRunAs() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_securityContext(t *testing.T) {
+	user, group := int64(1000), int64(2000)
+	tests := []struct {
+		name string
+		box  Box
+		want *SecurityContext
+	}{
+		{
+			name: "test happy path restricted",
+			box:  Box{SecurityProfile: SecurityRestricted, RunAs: &RunAs{User: user, Group: group}},
+			want: &SecurityContext{
+				RunAsNonRoot:             boolPtr(true),
+				RunAsUser:                &user,
+				RunAsGroup:               &group,
+				AllowPrivilegeEscalation: boolPtr(false),
+				ReadOnlyRootFilesystem:   boolPtr(true),
+				Capabilities:             &Capabilities{Drop: []string{"ALL"}},
+				SeccompProfile:           &SeccompProfile{Type: "RuntimeDefault"},
+			},
+		},
+		{
+			name: "test happy path baseline",
+			box:  Box{SecurityProfile: SecurityBaseline},
+			want: &SecurityContext{
+				AllowPrivilegeEscalation: boolPtr(false),
+				SeccompProfile:           &SeccompProfile{Type: "RuntimeDefault"},
+			},
+		},
+		{
+			name: "test happy path privileged",
+			box:  Box{SecurityProfile: SecurityPrivileged},
+		},
+		{
+			name: "test happy path without profile",
+			box:  Box{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.box.securityContext(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("securityContext() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_writableVolumes(t *testing.T) {
+	box := Box{
+		Name:            "eks",
+		Namespace:       "devbox",
+		User:            "developer",
+		SecurityProfile: SecurityRestricted,
+		RunAs:           &RunAs{User: 1000, Group: 1000},
+		SecretMounts:    []ObjectMount{{Name: "registry-token", Path: "/etc/registry"}},
+	}
+	pod := box.Pod()
+	wantVolumes := []Volume{
+		{Name: "secret-0", Secret: &SecretVolumeSource{SecretName: "registry-token"}},
+		{Name: "tmp", EmptyDir: &EmptyDirVolumeSource{}},
+		{Name: "home", EmptyDir: &EmptyDirVolumeSource{}},
+	}
+	if !reflect.DeepEqual(pod.Spec.Volumes, wantVolumes) {
+		t.Errorf("Pod() volumes = %v, want %v", pod.Spec.Volumes, wantVolumes)
+	}
+	wantMounts := []VolumeMount{
+		{Name: "secret-0", MountPath: "/etc/registry", ReadOnly: true},
+		{Name: "tmp", MountPath: "/tmp"},
+		{Name: "home", MountPath: "/home/developer"},
+	}
+	if !reflect.DeepEqual(pod.Spec.Containers[0].VolumeMounts, wantMounts) {
+		t.Errorf("Pod() volume mounts = %v, want %v", pod.Spec.Containers[0].VolumeMounts, wantMounts)
+	}
+	if pod.Spec.SecurityContext == nil || *pod.Spec.SecurityContext.FSGroup != 1000 {
+		t.Errorf("Pod() security context = %v, want fsGroup 1000", pod.Spec.SecurityContext)
+	}
+}
+
+func Test_imageRunAs(t *testing.T) {
+	tests := []struct {
+		name string
+		user string
+		want *RunAs
+	}{
+		{name: "test happy path", user: "65532:65532", want: &RunAs{User: 65532, Group: 65532}},
+		{name: "test happy path without group", user: "1000", want: &RunAs{User: 1000, Group: 1000}},
+		{name: "test crappy user name", user: "developer"},
+		{name: "test crappy root", user: "0"},
+		{name: "test crappy no user", user: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := imageRunAs(tt.user); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imageRunAs() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package devbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// DefaultProtectedNamespaces contains the namespaces whose workloads devboxes
+// are never patched into, in addition to any State configures.
+var DefaultProtectedNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// WorkloadKinds contains the kinds of workloads devboxes can be patched into
+// as sidecars.
+var WorkloadKinds = []string{"deployment", "statefulset"}
+
+// workload is the subset of a Kubernetes deployment or statefulset used to
+// patch a devbox sidecar into it.
+type workload struct {
+	Spec struct {
+		Selector labelSelector   `json:"selector"`
+		Template json.RawMessage `json:"template"`
+	} `json:"spec"`
+}
+
+// labelSelector is a Kubernetes label selector of workload pods.
+type labelSelector struct {
+	MatchLabels      map[string]string `json:"matchLabels"`
+	MatchExpressions []struct {
+		Key      string   `json:"key"`
+		Operator string   `json:"operator"`
+		Values   []string `json:"values"`
+	} `json:"matchExpressions"`
+}
+
+// podTemplate is the subset of a Kubernetes pod template used to patch a
+// devbox sidecar into it.
+type podTemplate struct {
+	Spec struct {
+		Containers []struct {
+			Name         string        `json:"name"`
+			VolumeMounts []VolumeMount `json:"volumeMounts"`
+		} `json:"containers"`
+		Volumes []struct {
+			Name string `json:"name"`
+		} `json:"volumes"`
+	} `json:"spec"`
+}
+
+// podList is the subset of a Kubernetes pod list used to find the pod of a
+// patched workload hosting a devbox sidecar.
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name              string  `json:"name"`
+			DeletionTimestamp *string `json:"deletionTimestamp"`
+		} `json:"metadata"`
+		Spec struct {
+			Containers []struct {
+				Name string `json:"name"`
+			} `json:"containers"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// jsonPatchOp is a RFC 6902 JSON patch operation.
+type jsonPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// isProtectedNamespace tests if a Kubernetes namespace is one of protected
+// namespaces or of DefaultProtectedNamespaces, which are always protected.
+func isProtectedNamespace(protected []string, namespace string) bool {
+	return util.ContainsString(DefaultProtectedNamespaces, namespace) || util.ContainsString(protected, namespace)
+}
+
+// ParseWorkload parses a workload reference in kind/name format.
+func ParseWorkload(s string) (string, string, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("malformed workload %q, want kind/name", s)
+	}
+	kind := strings.ToLower(parts[0])
+	if !util.ContainsString(WorkloadKinds, kind) {
+		return "", "", fmt.Errorf("invalid workload kind %q, want one of %s", parts[0], strings.Join(WorkloadKinds, ", "))
+	}
+	return kind, parts[1], nil
+}
+
+// AttachWorkload attaches a Kubernetes Box to an existing deployment or
+// statefulset by patching it into its pod template as a sidecar container
+// sharing the volume mounts of its target container, with the volumes of its
+// Secret and ConfigMap mounts, and waits until the rollout completes. If
+// container is empty, the first container of the pod template is targeted.
+// Workloads in protected namespaces, or in DefaultProtectedNamespaces, are
+// never patched. The original pod template is recorded in
+// the returned Box, which is the attached Box to save in state, and is
+// restored when it is stopped.
+func (box Box) AttachWorkload(kind string, name string, container string, protected []string, timeout time.Duration) (Box, error) {
+	if box.Namespace == "" {
+		return box, errors.New("only Kubernetes devboxes can be attached to workloads")
+	}
+	if isProtectedNamespace(protected, box.Namespace) {
+		return box, fmt.Errorf("%s %s is in protected namespace %s", kind, name, box.Namespace)
+	}
+	if box.Attachment != nil {
+		return box, fmt.Errorf("devbox %s is already attached to %s", box.Name, box.Attachment)
+	}
+	box, err := box.verifyImage()
+	if err != nil {
+		return box, err
+	}
+
+	// Get the workload and the volume mounts of its target container.
+	out, err := util.OutputCommand("kubectl", box.kubectlArgs("get", kind, name, "-o", "json")...)
+	if err != nil {
+		return box, fmt.Errorf("cannot get %s %s: %v", kind, name, err)
+	}
+	var target workload
+	if err := json.Unmarshal(out, &target); err != nil {
+		return box, err
+	}
+	var template podTemplate
+	if err := json.Unmarshal(target.Spec.Template, &template); err != nil {
+		return box, err
+	}
+	var volumeMounts []VolumeMount
+	found := false
+	for i, c := range template.Spec.Containers {
+		if c.Name == box.Name {
+			return box, fmt.Errorf("%s %s already has a %s container", kind, name, box.Name)
+		}
+		if !found && ((container == "" && i == 0) || c.Name == container) {
+			container = c.Name
+			volumeMounts = c.VolumeMounts
+			found = true
+		}
+	}
+	if !found {
+		return box, fmt.Errorf("%s %s has no %s container", kind, name, container)
+	}
+
+	// Patch the sidecar into the workload pod template.
+	ops, err := box.sidecarPatch(template, volumeMounts)
+	if err != nil {
+		return box, fmt.Errorf("cannot patch %s %s: %v", kind, name, err)
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return box, err
+	}
+	if config.Verbose {
+		fmt.Printf("msg: patching devbox %s into %s %s in %s namespace in cluster with %s kubeconfig\n", box.Name, kind, name, box.Namespace, box.Kubeconfig)
+	}
+	err = util.ExecCommand("kubectl", box.kubectlArgs("patch", kind, name, "--type=json", fmt.Sprintf("--patch=%s", patch))...)
+	if err != nil {
+		return box, err
+	}
+
+	box.Attachment = &Attachment{
+		Mode:             AttachSidecar,
+		Container:        container,
+		Workload:         fmt.Sprintf("%s/%s", kind, name),
+		OriginalTemplate: string(target.Spec.Template),
+	}
+	if config.DryRun {
+		return box, nil
+	}
+
+	// Wait for the rollout and find a pod hosting the sidecar.
+	err = util.ExecCommand("kubectl", box.kubectlArgs("rollout", "status", box.Attachment.Workload, fmt.Sprintf("--timeout=%s", timeout))...)
+	if err != nil {
+		return box, fmt.Errorf("rollout of %s failed, restore it with devbox stop: %v", box.Attachment.Workload, err)
+	}
+	pod, err := box.findSidecarPod(target.Spec.Selector)
+	if err != nil {
+		return box, err
+	}
+	box.Attachment.Pod = pod
+	return box, nil
+}
+
+// sidecarPatch returns the JSON patch operations adding a Box as a sidecar
+// container to a pod template, sharing the volume mounts of its target
+// container. The volumes of its Secret and ConfigMap mounts are added to the
+// pod template, named after the Box so that they do not clash with those of
+// the workload.
+func (box Box) sidecarPatch(template podTemplate, volumeMounts []VolumeMount) ([]jsonPatchOp, error) {
+	sidecar := box.container()
+	if sidecar.Command == nil {
+		sidecar.Command = DefaultKeepAlive.Command
+		sidecar.Args = DefaultKeepAlive.Args
+	}
+	// The sidecar shares the volumes of the target container, not those of
+	// a devbox pod, so its root filesystem must stay writable.
+	if sidecar.SecurityContext != nil {
+		sidecar.SecurityContext.ReadOnlyRootFilesystem = nil
+	}
+	sidecar.VolumeMounts = append([]VolumeMount{}, volumeMounts...)
+	volumes, objectMounts := box.objectVolumes()
+	names := make(map[string]string)
+	for i := range volumes {
+		name := fmt.Sprintf("%s-%s", box.Name, volumes[i].Name)
+		for _, volume := range template.Spec.Volumes {
+			if volume.Name == name {
+				return nil, fmt.Errorf("pod template already has a %s volume", name)
+			}
+		}
+		names[volumes[i].Name] = name
+		volumes[i].Name = name
+	}
+	for _, mount := range objectMounts {
+		mount.Name = names[mount.Name]
+		sidecar.VolumeMounts = append(sidecar.VolumeMounts, mount)
+	}
+
+	ops := []jsonPatchOp{
+		{Op: "add", Path: "/spec/template/spec/containers/-", Value: sidecar},
+	}
+	if len(volumes) == 0 {
+		return ops, nil
+	}
+	// Volumes can only be appended to an existing list.
+	if template.Spec.Volumes == nil {
+		return append(ops, jsonPatchOp{Op: "add", Path: "/spec/template/spec/volumes", Value: volumes}), nil
+	}
+	for _, volume := range volumes {
+		ops = append(ops, jsonPatchOp{Op: "add", Path: "/spec/template/spec/volumes/-", Value: volume})
+	}
+	return ops, nil
+}
+
+// String returns the selector in kubectl --selector format.
+func (selector labelSelector) String() (string, error) {
+	var requirements []string
+	for key, value := range selector.MatchLabels {
+		requirements = append(requirements, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(requirements)
+	for _, expression := range selector.MatchExpressions {
+		values := strings.Join(expression.Values, ",")
+		switch expression.Operator {
+		case "In":
+			requirements = append(requirements, fmt.Sprintf("%s in (%s)", expression.Key, values))
+		case "NotIn":
+			requirements = append(requirements, fmt.Sprintf("%s notin (%s)", expression.Key, values))
+		case "Exists":
+			requirements = append(requirements, expression.Key)
+		case "DoesNotExist":
+			requirements = append(requirements, fmt.Sprintf("!%s", expression.Key))
+		default:
+			return "", fmt.Errorf("unknown selector operator %q", expression.Operator)
+		}
+	}
+	return strings.Join(requirements, ","), nil
+}
+
+// findSidecarPod returns the name of a running pod with the devbox sidecar
+// container matching a workload selector.
+func (box Box) findSidecarPod(selector labelSelector) (string, error) {
+	labels, err := selector.String()
+	if err != nil {
+		return "", err
+	}
+	out, err := util.OutputCommand("kubectl", box.kubectlArgs("get", "pods", "-l", labels, "-o", "json")...)
+	if err != nil {
+		return "", err
+	}
+	var pods podList
+	if err := json.Unmarshal(out, &pods); err != nil {
+		return "", err
+	}
+	return pods.sidecarPod(box.Name)
+}
+
+// sidecarPod returns the name of a running pod with a devbox sidecar
+// container.
+func (pods podList) sidecarPod(name string) (string, error) {
+	for _, pod := range pods.Items {
+		if pod.Metadata.DeletionTimestamp != nil || pod.Status.Phase != "Running" {
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			if c.Name == name {
+				return pod.Metadata.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no running pod with devbox %s container found", name)
+}
+
+// restoreWorkload restores the original pod template of the workload a Box
+// is attached to as a sidecar.
+func (box Box) restoreWorkload() error {
+	var template interface{}
+	if err := json.Unmarshal([]byte(box.Attachment.OriginalTemplate), &template); err != nil {
+		return fmt.Errorf("cannot restore %s from state: %v", box.Attachment.Workload, err)
+	}
+	patch, err := json.Marshal([]jsonPatchOp{
+		{Op: "replace", Path: "/spec/template", Value: template},
+	})
+	if err != nil {
+		return err
+	}
+	kind, name, err := ParseWorkload(box.Attachment.Workload)
+	if err != nil {
+		return err
+	}
+	return util.ExecCommand("kubectl", box.kubectlArgs("patch", kind, name, "--type=json", fmt.Sprintf("--patch=%s", patch))...)
+}
@@ -0,0 +1,248 @@
+package devbox
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseWorkload(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		wantKind string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "test happy path with deployment", s: "deployment/web", wantKind: "deployment", wantName: "web"},
+		{name: "test happy path with statefulset", s: "StatefulSet/db", wantKind: "statefulset", wantName: "db"},
+		{name: "test crappy kind", s: "daemonset/agent", wantErr: true},
+		{name: "test missing name", s: "deployment/", wantErr: true},
+		{name: "test missing kind", s: "web", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, name, err := ParseWorkload(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseWorkload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if kind != tt.wantKind || name != tt.wantName {
+				t.Errorf("ParseWorkload() got = %s, %s, want %s, %s", kind, name, tt.wantKind, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestLabelSelector_String(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "test happy path",
+			selector: `{"matchLabels": {"tier": "web", "app": "shop"}}`,
+			want:     "app=shop,tier=web",
+		},
+		{
+			name: "test happy path with expressions",
+			selector: `{"matchLabels": {"app": "shop"}, "matchExpressions": [
+				{"key": "tier", "operator": "In", "values": ["web", "api"]},
+				{"key": "env", "operator": "NotIn", "values": ["prod"]},
+				{"key": "canary", "operator": "Exists"},
+				{"key": "legacy", "operator": "DoesNotExist"}]}`,
+			want: "app=shop,tier in (web,api),env notin (prod),canary,!legacy",
+		},
+		{
+			name:     "test crappy operator",
+			selector: `{"matchExpressions": [{"key": "tier", "operator": "Gt", "values": ["1"]}]}`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var selector labelSelector
+			if err := json.Unmarshal([]byte(tt.selector), &selector); err != nil {
+				t.Fatal(err)
+			}
+			got, err := selector.String()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("String() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("String() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodList_sidecarPod(t *testing.T) {
+	tests := []struct {
+		name    string
+		pods    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "test happy path",
+			pods: `{"items": [
+				{"metadata": {"name": "web-old"}, "spec": {"containers": [{"name": "web"}]}, "status": {"phase": "Running"}},
+				{"metadata": {"name": "web-new"}, "spec": {"containers": [{"name": "web"}, {"name": "devbox"}]}, "status": {"phase": "Running"}}]}`,
+			want: "web-new",
+		},
+		{
+			name: "test crappy terminating pod",
+			pods: `{"items": [
+				{"metadata": {"name": "web-new", "deletionTimestamp": "2021-06-01T12:00:00Z"}, "spec": {"containers": [{"name": "devbox"}]}, "status": {"phase": "Running"}}]}`,
+			wantErr: true,
+		},
+		{
+			name: "test crappy pending pod",
+			pods: `{"items": [
+				{"metadata": {"name": "web-new"}, "spec": {"containers": [{"name": "devbox"}]}, "status": {"phase": "Pending"}}]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pods podList
+			if err := json.Unmarshal([]byte(tt.pods), &pods); err != nil {
+				t.Fatal(err)
+			}
+			got, err := pods.sidecarPod("devbox")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sidecarPod() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("sidecarPod() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_AttachWorkload(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		protected []string
+	}{
+		{
+			name:      "test crappy default protected namespace",
+			namespace: "kube-system",
+		},
+		{
+			name:      "test crappy protected namespace",
+			namespace: "payments",
+			protected: []string{"payments"},
+		},
+		{
+			name:      "test crappy default protected namespace with protected namespaces",
+			namespace: "kube-system",
+			protected: []string{"payments"},
+		},
+		{
+			name: "test crappy Docker devbox",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := Box{Image: "ubuntu", Name: "devbox", Namespace: tt.namespace}
+			got, err := box.AttachWorkload("deployment", "web", "", tt.protected, time.Minute)
+			if err == nil {
+				t.Errorf("AttachWorkload() error = nil, want error")
+			}
+			if got.Attachment != nil {
+				t.Errorf("AttachWorkload() attachment = %v, want nil", got.Attachment)
+			}
+		})
+	}
+}
+
+func TestBox_sidecarPatch(t *testing.T) {
+	targetMounts := []VolumeMount{{Name: "data", MountPath: "/data"}}
+	tests := []struct {
+		name      string
+		template  string
+		box       Box
+		wantPaths []string
+		wantMount []VolumeMount
+		wantErr   bool
+	}{
+		{
+			name:      "test happy path",
+			template:  `{"spec": {"containers": [{"name": "web"}]}}`,
+			box:       Box{Image: "ubuntu", Name: "devbox", Namespace: "dev"},
+			wantPaths: []string{"/spec/template/spec/containers/-"},
+			wantMount: targetMounts,
+		},
+		{
+			name:     "test happy path with object mounts",
+			template: `{"spec": {"containers": [{"name": "web"}], "volumes": [{"name": "data"}]}}`,
+			box: Box{
+				Image:           "ubuntu",
+				Name:            "devbox",
+				Namespace:       "dev",
+				SecretMounts:    []ObjectMount{{Name: "creds", Path: "/creds"}},
+				ConfigMapMounts: []ObjectMount{{Name: "settings", Path: "/etc/settings"}},
+			},
+			wantPaths: []string{"/spec/template/spec/containers/-", "/spec/template/spec/volumes/-", "/spec/template/spec/volumes/-"},
+			wantMount: append(targetMounts,
+				VolumeMount{Name: "devbox-secret-0", MountPath: "/creds", ReadOnly: true},
+				VolumeMount{Name: "devbox-configmap-0", MountPath: "/etc/settings", ReadOnly: true}),
+		},
+		{
+			name:     "test happy path with object mounts without volumes",
+			template: `{"spec": {"containers": [{"name": "web"}]}}`,
+			box: Box{
+				Image:        "ubuntu",
+				Name:         "devbox",
+				Namespace:    "dev",
+				SecretMounts: []ObjectMount{{Name: "creds", Path: "/creds"}},
+			},
+			wantPaths: []string{"/spec/template/spec/containers/-", "/spec/template/spec/volumes"},
+			wantMount: append(targetMounts, VolumeMount{Name: "devbox-secret-0", MountPath: "/creds", ReadOnly: true}),
+		},
+		{
+			name:     "test crappy volume name clash",
+			template: `{"spec": {"containers": [{"name": "web"}], "volumes": [{"name": "devbox-secret-0"}]}}`,
+			box: Box{
+				Image:        "ubuntu",
+				Name:         "devbox",
+				Namespace:    "dev",
+				SecretMounts: []ObjectMount{{Name: "creds", Path: "/creds"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var template podTemplate
+			if err := json.Unmarshal([]byte(tt.template), &template); err != nil {
+				t.Fatal(err)
+			}
+			ops, err := tt.box.sidecarPatch(template, targetMounts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sidecarPatch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var paths []string
+			for _, op := range ops {
+				paths = append(paths, op.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("sidecarPatch() paths = %v, want %v", paths, tt.wantPaths)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := ops[0].Value.(Container).VolumeMounts; !reflect.DeepEqual(got, tt.wantMount) {
+				t.Errorf("sidecarPatch() volume mounts = %+v, want %+v", got, tt.wantMount)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package devbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// Snapshot commits the container of a started Docker Box to an image tagged
+// with tag, capturing any changes made to its filesystem since it was started,
+// and pushes it if push is true.
+func (box Box) Snapshot(tag string, push bool) error {
+	// Kubernetes container runtimes cannot commit containers to images.
+	if box.Namespace != "" {
+		return errors.New("only Docker devboxes can be snapshotted, see devbox snapshot --help for alternatives")
+	}
+	if tag == "" {
+		return errors.New("snapshot tag cannot be empty")
+	}
+	if config.Verbose {
+		fmt.Printf("msg: committing devbox %s to image %s in docker\n", box.Name, tag)
+	}
+	message := fmt.Sprintf("--message=devbox %s snapshot of %s", box.Name, box.Image)
+	if err := util.ExecCommand("docker", "commit", message, box.Name, tag); err != nil {
+		return err
+	}
+	if push {
+		return util.ExecCommand("docker", "push", tag)
+	}
+	return nil
+}
+
+// SetupSecrets returns the local files containing secrets in the manifest
+// items of the last setup of a Box, with the kinds of secrets they contain.
+// Such files may have been copied to the Box, depending on the secret policy
+// of its setup, and be committed to its snapshots.
+func (box Box) SetupSecrets() (map[string]string, error) {
+	secrets := make(map[string]string)
+	for _, manifestType := range box.Runtime.SetupTypes {
+		for _, item := range defaultManifest[manifestType] {
+			if item.Path == "" {
+				continue
+			}
+			src, err := resolvePath(item.Path)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := os.Stat(src); os.IsNotExist(err) {
+				continue
+			}
+			found, err := findSecrets(src)
+			if err != nil {
+				return nil, err
+			}
+			for path, kind := range found {
+				secrets[path] = kind
+			}
+		}
+	}
+	return secrets, nil
+}
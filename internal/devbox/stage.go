@@ -0,0 +1,71 @@
+package devbox
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// stageDir copies a directory to a staging directory with the same base name
+// in a new temporary directory, omitting files for which skip returns true,
+// and returns the staged directory and the temporary directory to remove once
+// it is no longer needed. Symbolic links are followed.
+func stageDir(src string, skip func(path string) (bool, error)) (string, string, error) {
+	tmp, err := ioutil.TempDir("", "devbox-stage")
+	if err != nil {
+		return "", "", err
+	}
+	src = filepath.Clean(src)
+	staged := filepath.Join(tmp, filepath.Base(src))
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(staged, rel)
+		if info.IsDir() {
+			return os.MkdirAll(dst, info.Mode().Perm())
+		}
+		// Links are resolved, as their targets may be outside the directory.
+		if info.Mode()&os.ModeSymlink != 0 {
+			if info, err = os.Stat(path); err != nil || info.IsDir() {
+				return nil
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		skipped, err := skip(path)
+		if err != nil || skipped {
+			return err
+		}
+		return copyFile(path, dst, info.Mode().Perm())
+	})
+	if err != nil {
+		os.RemoveAll(tmp)
+		return "", "", err
+	}
+	return staged, tmp, nil
+}
+
+// copyFile copies a file to dst with permissions perm.
+func copyFile(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 
 	"github.com/ghodss/yaml"
 	"github.com/mitchellh/go-homedir"
 
+	"github.com/mojochao/devbox/internal/audit"
 	"github.com/mojochao/devbox/internal/config"
 )
 
@@ -23,9 +25,10 @@ type Boxes = map[BoxID]Box
 // NewState returns a new State ready for use.
 func NewState(path string) State {
 	return State{
-		Active: "",
-		Boxes:  make(Boxes),
-		Path:   path,
+		Active:              "",
+		Boxes:               make(Boxes),
+		Path:                path,
+		ProtectedNamespaces: DefaultProtectedNamespaces,
 	}
 }
 
@@ -52,6 +55,15 @@ type State struct {
 
 	// Path is the path to the state file.
 	Path string `yaml:"path"`
+
+	// ProtectedNamespaces are the Kubernetes namespaces whose workloads
+	// devboxes are never patched into, in addition to
+	// DefaultProtectedNamespaces.
+	ProtectedNamespaces []string `yaml:"protectedNamespaces"`
+
+	// Encryption configures the encryption of the sensitive fields of the
+	// Box items at rest. If nil, they are not encrypted.
+	Encryption *Encryption `yaml:"encryption"`
 }
 
 // AddDevbox adds a Box to State.
@@ -61,6 +73,16 @@ func (boxes State) AddDevbox(id BoxID, box Box) error {
 	}
 	boxes.Boxes[id] = box
 	boxes.Active = id
+	audit.RecordBox(box.auditBox(id, boxes.Encryption != nil))
+	return saveState(boxes.Path, boxes)
+}
+
+// UpdateDevbox updates a Box in State.
+func (boxes State) UpdateDevbox(id BoxID, box Box) error {
+	if !boxes.ContainsDevbox(id) {
+		return errors.New("devbox with id not found")
+	}
+	boxes.Boxes[id] = box
 	return saveState(boxes.Path, boxes)
 }
 
@@ -69,6 +91,7 @@ func (boxes State) RemoveDevbox(id BoxID) error {
 	if !boxes.ContainsDevbox(id) {
 		return errors.New("devbox with id not found")
 	}
+	audit.RecordBox(boxes.Boxes[id].auditBox(id, boxes.Encryption != nil))
 	delete(boxes.Boxes, id)
 	if boxes.Active == id {
 		boxes.Active = ""
@@ -88,9 +111,16 @@ func (boxes State) GetDevbox(id BoxID) (Box, error) {
 	if !ok {
 		return Box{}, errors.New("devbox with id not found")
 	}
+	audit.RecordBox(box.auditBox(id, boxes.Encryption != nil))
 	return box, nil
 }
 
+// IsProtectedNamespace tests if workloads in a Kubernetes namespace must not
+// be patched.
+func (boxes State) IsProtectedNamespace(namespace string) bool {
+	return isProtectedNamespace(boxes.ProtectedNamespaces, namespace)
+}
+
 // Save saves State. If no paths are provided, the path from which State
 // was loaded will be used.
 func (boxes State) Save(paths ...string) error {
@@ -119,7 +149,12 @@ func loadState(path string) (State, error) {
 	if err != nil {
 		return state, err
 	}
-	err = yaml.Unmarshal(buf, &state)
+	if err := yaml.Unmarshal(buf, &state); err != nil {
+		return state, err
+	}
+	if state.Encryption != nil {
+		state.Boxes, err = state.transformBoxes(decryptString)
+	}
 	return state, err
 }
 
@@ -128,9 +163,19 @@ func saveState(path string, state State) error {
 	if err != nil {
 		return err
 	}
+	if state.Encryption != nil {
+		if state.Boxes, err = state.transformBoxes(encryptString); err != nil {
+			return err
+		}
+	}
 	buf, err := yaml.Marshal(state)
 	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(path, buf, 0644)
+	// The state file may contain secrets, even if encrypted, so it is only
+	// readable by its owner, including if it was created more permissively.
+	if err := ioutil.WriteFile(path, buf, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
 }
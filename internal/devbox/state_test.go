@@ -4,6 +4,8 @@ import (
 	"os"
 	"reflect"
 	"testing"
+
+	"github.com/mojochao/devbox/internal/audit"
 )
 
 var state = State{
@@ -209,3 +211,52 @@ func TestState_GetDevbox(t *testing.T) {
 		})
 	}
 }
+
+func TestState_IsProtectedNamespace(t *testing.T) {
+	tests := []struct {
+		name      string
+		protected []string
+		namespace string
+		want      bool
+	}{
+		{name: "test default protected", namespace: "kube-system", want: true},
+		{name: "test default unprotected", namespace: "devbox", want: false},
+		{name: "test configured protected", protected: []string{"prod"}, namespace: "prod", want: true},
+		{name: "test configured unprotected", protected: []string{"prod"}, namespace: "devbox", want: false},
+		{name: "test configured with default protected", protected: []string{"prod"}, namespace: "kube-system", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := State{ProtectedNamespaces: tt.protected}
+			if got := state.IsProtectedNamespace(tt.namespace); got != tt.want {
+				t.Errorf("IsProtectedNamespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_auditBox(t *testing.T) {
+	box := Box{Name: "devbox", Namespace: "dev", Kubeconfig: "/home/alice/.kube/prod-eks"}
+	tests := []struct {
+		name      string
+		encrypted bool
+		want      audit.Box
+	}{
+		{
+			name: "test happy path",
+			want: audit.Box{ID: "eks", Runtime: "kubernetes", Name: "devbox", Namespace: "dev", Kubeconfig: "/home/alice/.kube/prod-eks"},
+		},
+		{
+			name:      "test happy path encrypted",
+			encrypted: true,
+			want:      audit.Box{ID: "eks", Runtime: "kubernetes", Name: "devbox", Namespace: "dev"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := box.auditBox("eks", tt.encrypted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("auditBox() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
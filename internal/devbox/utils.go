@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"os/user"
+	"sort"
 	"strings"
 
 	"github.com/mojochao/devbox/internal/config"
@@ -40,3 +41,13 @@ func getCurrentUsername() string {
 	}
 	return currentUser.Username
 }
+
+// sortedKeys returns the keys of a map in sorted order.
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
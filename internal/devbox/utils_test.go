@@ -1,10 +1,32 @@
 package devbox
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
+// fakeCommand installs an executable shell script as a command first in PATH
+// until the test ends, which logs its arguments to a file in dir. It returns
+// dir and a function returning the logged arguments of each execution.
+func fakeCommand(t *testing.T, name string, script string) (string, func() []string) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	contents := "#!/bin/sh\necho \"$*\" >> " + log + "\n" + script
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	t.Cleanup(func() { os.Setenv("PATH", path) })
+	return dir, func() []string {
+		out, _ := os.ReadFile(log)
+		return strings.Split(strings.TrimSpace(string(out)), "\n")
+	}
+}
+
 func Test_getCurrentUsername(t *testing.T) {
 	tests := []struct {
 		name string
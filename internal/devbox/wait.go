@@ -0,0 +1,251 @@
+package devbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mojochao/devbox/internal/config"
+	"github.com/mojochao/devbox/internal/util"
+)
+
+// DefaultStartTimeout is the default time to wait for a started Box to be
+// ready.
+const DefaultStartTimeout = 5 * time.Minute
+
+// pollInterval is the interval between readiness checks.
+const pollInterval = 2 * time.Second
+
+// Readiness contains configuration determining when a started Box is ready.
+type Readiness struct {
+	// Command is an optional shell command executed in the devbox that must
+	// succeed for it to be ready.
+	Command string `yaml:"command"`
+
+	// Timeout is the maximum time to wait for the devbox to be ready, in Go
+	// duration format, for example "5m".
+	Timeout string `yaml:"timeout"`
+}
+
+// Validate ensures a Readiness timeout is a valid duration.
+func (readiness Readiness) Validate() error {
+	if readiness.Timeout == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(readiness.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid readiness timeout %q: %v", readiness.Timeout, err)
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("invalid readiness timeout %q: must be positive", readiness.Timeout)
+	}
+	return nil
+}
+
+// StartTimeout returns the configured readiness timeout of a Box, or the
+// DefaultStartTimeout if none is configured.
+func (box Box) StartTimeout() time.Duration {
+	timeout, err := time.ParseDuration(box.Readiness.Timeout)
+	if err != nil || timeout <= 0 {
+		return DefaultStartTimeout
+	}
+	return timeout
+}
+
+// containerFailureReasons contains the waiting reasons of a Kubernetes
+// container that will not resolve without intervention.
+var containerFailureReasons = map[string]string{
+	"ImagePullBackOff":           "image cannot be pulled, check the image name and registry credentials",
+	"InvalidImageName":           "image name is invalid",
+	"CrashLoopBackOff":           "container keeps exiting, see why with devbox logs --previous",
+	"CreateContainerConfigError": "container cannot be configured, check referenced secrets and config maps",
+	"CreateContainerError":       "container cannot be created",
+	"RunContainerError":          "container cannot be run",
+}
+
+// WaitReady waits until a started Box is running and any readiness command
+// succeeds, or returns an error if it fails or the timeout elapses.
+func (box Box) WaitReady(timeout time.Duration) error {
+	if config.DryRun {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	var err error
+	if box.Namespace == "" {
+		err = box.waitContainerRunning(deadline)
+	} else {
+		err = box.waitPodReady(deadline)
+	}
+	if err != nil {
+		return err
+	}
+	return box.waitReadinessCommand(deadline)
+}
+
+// dockerState is the subset of docker container state used to wait for it.
+type dockerState struct {
+	Status   string
+	ExitCode int
+	Error    string
+}
+
+func (box Box) waitContainerRunning(deadline time.Time) error {
+	for {
+		out, err := util.OutputCommand("docker", "inspect", "--format", "{{json .State}}", box.Name)
+		if err != nil {
+			return fmt.Errorf("devbox container %s not found, it may have exited and been removed: %v", box.Name, err)
+		}
+		var state dockerState
+		if err := json.Unmarshal(out, &state); err != nil {
+			return err
+		}
+		switch state.Status {
+		case "running":
+			return nil
+		case "exited", "dead":
+			msg := fmt.Sprintf("devbox container %s exited with code %d", box.Name, state.ExitCode)
+			if state.Error != "" {
+				msg = fmt.Sprintf("%s: %s", msg, state.Error)
+			}
+			return fmt.Errorf("%s, check that the image runs a long-running command", msg)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for devbox container %s in %s state", box.Name, state.Status)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// podStatus is the subset of Kubernetes pod status used to wait for it.
+type podStatus struct {
+	Status struct {
+		Phase      string `json:"phase"`
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+		ContainerStatuses []containerStatus `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// containerStatus is the subset of Kubernetes container status used to wait
+// for it.
+type containerStatus struct {
+	Name  string `json:"name"`
+	State struct {
+		Waiting *struct {
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"waiting"`
+	} `json:"state"`
+}
+
+// podEvents is the subset of a Kubernetes event list used to show progress.
+type podEvents struct {
+	Items []struct {
+		Metadata struct {
+			UID string `json:"uid"`
+		} `json:"metadata"`
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"items"`
+}
+
+// podReady returns whether the pod of a Box is ready in its JSON status and
+// its phase, or an error if it will not become ready without intervention.
+func (box Box) podReady(out []byte) (bool, string, error) {
+	var pod podStatus
+	if err := json.Unmarshal(out, &pod); err != nil {
+		return false, "", err
+	}
+	phase := pod.Status.Phase
+	if phase == "Failed" || phase == "Succeeded" {
+		return false, phase, fmt.Errorf("devbox pod %s is %s, check that the image runs a long-running command", box.Name, strings.ToLower(phase))
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting == nil {
+			continue
+		}
+		if hint, ok := containerFailureReasons[status.State.Waiting.Reason]; ok {
+			return false, phase, fmt.Errorf("devbox pod %s is in %s: %s: %s", box.Name, status.State.Waiting.Reason, hint, status.State.Waiting.Message)
+		}
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == "Ready" && condition.Status == "True" {
+			return true, phase, nil
+		}
+	}
+	return false, phase, nil
+}
+
+func (box Box) waitPodReady(deadline time.Time) error {
+	seen := make(map[string]bool)
+	for {
+		box.printPodEvents(seen)
+
+		out, err := util.OutputCommand("kubectl", box.kubectlArgs("get", "pod", box.Name, "-o", "json")...)
+		if err != nil {
+			return err
+		}
+		ready, phase, err := box.podReady(out)
+		if ready || err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for devbox pod %s in %s phase to be ready", box.Name, phase)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// printPodEvents prints events of a pod not yet seen, such as scheduling and
+// image pull progress.
+func (box Box) printPodEvents(seen map[string]bool) {
+	selector := fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s", box.Name)
+	out, err := util.OutputCommand("kubectl", box.kubectlArgs("get", "events", "--field-selector", selector, "--sort-by", ".lastTimestamp", "-o", "json")...)
+	if err != nil {
+		return
+	}
+	var events podEvents
+	if err := json.Unmarshal(out, &events); err != nil {
+		return
+	}
+	for _, event := range events.Items {
+		if seen[event.Metadata.UID] {
+			continue
+		}
+		seen[event.Metadata.UID] = true
+		fmt.Printf("devbox %s: %s: %s\n", box.Name, event.Reason, event.Message)
+	}
+}
+
+func (box Box) waitReadinessCommand(deadline time.Time) error {
+	if box.Readiness.Command == "" {
+		return nil
+	}
+	name, args := box.execArgs("sh", "-c", box.Readiness.Command)
+	if config.Verbose {
+		fmt.Printf("cmd: %s %s\n", name, strings.Join(args, " "))
+	}
+	// The readiness command is killed at the deadline, so that a hanging
+	// command or exec does not wait forever.
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	for {
+		// Output of the readiness command is discarded, as it is expected
+		// to fail until the devbox is ready.
+		err := exec.CommandContext(ctx, name, args...).Run()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for devbox %s readiness command to succeed: %v", box.Name, err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,148 @@
+package devbox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBox_StartTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		readiness Readiness
+		want      time.Duration
+		wantErr   bool
+	}{
+		{
+			name:      "test default timeout",
+			readiness: Readiness{},
+			want:      DefaultStartTimeout,
+		},
+		{
+			name:      "test happy path",
+			readiness: Readiness{Command: "test -f /tmp/ready", Timeout: "90s"},
+			want:      90 * time.Second,
+		},
+		{
+			name:      "test crappy timeout",
+			readiness: Readiness{Timeout: "soon"},
+			want:      DefaultStartTimeout,
+			wantErr:   true,
+		},
+		{
+			name:      "test negative timeout",
+			readiness: Readiness{Timeout: "-1m"},
+			want:      DefaultStartTimeout,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.readiness.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			box := Box{Readiness: tt.readiness}
+			if got := box.StartTimeout(); got != tt.want {
+				t.Errorf("StartTimeout() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBox_podReady(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		wantReady bool
+		wantPhase string
+		wantErr   bool
+	}{
+		{
+			name:      "test happy path ready",
+			status:    `{"status":{"phase":"Running","conditions":[{"type":"PodScheduled","status":"True"},{"type":"Ready","status":"True"}]}}`,
+			wantReady: true,
+			wantPhase: "Running",
+		},
+		{
+			name:      "test happy path not ready",
+			status:    `{"status":{"phase":"Running","conditions":[{"type":"Ready","status":"False","reason":"ContainersNotReady"}]}}`,
+			wantPhase: "Running",
+		},
+		{
+			name:      "test happy path pulling image",
+			status:    `{"status":{"phase":"Pending","containerStatuses":[{"name":"devbox","state":{"waiting":{"reason":"ContainerCreating"}}}]}}`,
+			wantPhase: "Pending",
+		},
+		{
+			name:   "test happy path no status yet",
+			status: `{}`,
+		},
+		{
+			name:      "test crappy image pull back-off",
+			status:    `{"status":{"phase":"Pending","containerStatuses":[{"name":"devbox","state":{"waiting":{"reason":"ImagePullBackOff","message":"not found"}}}]}}`,
+			wantPhase: "Pending",
+			wantErr:   true,
+		},
+		{
+			name:      "test crappy crash loop back-off",
+			status:    `{"status":{"phase":"Running","containerStatuses":[{"name":"devbox","state":{"waiting":{"reason":"CrashLoopBackOff"}}}]}}`,
+			wantPhase: "Running",
+			wantErr:   true,
+		},
+		{
+			name:      "test crappy exited pod",
+			status:    `{"status":{"phase":"Succeeded"}}`,
+			wantPhase: "Succeeded",
+			wantErr:   true,
+		},
+		{
+			name:    "test crappy invalid status",
+			status:  `not json`,
+			wantErr: true,
+		},
+	}
+	box := Box{Name: "devbox", Namespace: "dev"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, phase, err := box.podReady([]byte(tt.status))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("podReady() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if ready != tt.wantReady || phase != tt.wantPhase {
+				t.Errorf("podReady() = %v, %q, want %v, %q", ready, phase, tt.wantReady, tt.wantPhase)
+			}
+		})
+	}
+}
+
+func TestBox_waitReadinessCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		wantErr bool
+	}{
+		{
+			name:   "test happy path ready",
+			script: "exit 0",
+		},
+		{
+			name:    "test crappy hanging command killed at deadline",
+			script:  "exec sleep 60",
+			wantErr: true,
+		},
+	}
+	box := Box{Name: "devbox", Readiness: Readiness{Command: "test -f /tmp/ready"}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeCommand(t, "docker", tt.script)
+			start := time.Now()
+			err := box.waitReadinessCommand(start.Add(500 * time.Millisecond))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("waitReadinessCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > pollInterval {
+				t.Errorf("waitReadinessCommand() took %v, want it killed at deadline", elapsed)
+			}
+		})
+	}
+}
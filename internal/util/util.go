@@ -1,6 +1,7 @@
 package util
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -38,6 +39,36 @@ func ExecCommand(name string, args ...string) error {
 	return cmd.Run()
 }
 
+// ExecCommandWithInput executes a command with input provided on stdin.
+func ExecCommandWithInput(input []byte, name string, args ...string) error {
+	if config.DryRun || config.Verbose {
+		fmt.Printf("cmd: %s %s\n", name, strings.Join(args, " "))
+		if config.DryRun {
+			return nil
+		}
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// OutputCommand executes a command and returns its output. Unlike
+// ExecCommand, the command is executed in dry run mode, so it must only be
+// used for commands that do not change any state.
+func OutputCommand(name string, args ...string) ([]byte, error) {
+	if config.Verbose {
+		fmt.Printf("cmd: %s %s\n", name, strings.Join(args, " "))
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
 // FileExists tests if path is a file.
 func FileExists(path string) bool {
 	path, _ = homedir.Expand(path)